@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/config"
+	"github.com/bagasadiii/maxcloud_vps/migration"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"gopkg.in/yaml.v3"
+)
+
+func runCommand(name string, args []string) {
+	switch name {
+	case "export-billing":
+		exportBillingCommand(args)
+	case "migrate":
+		migrateCommand(args)
+	case "config":
+		configCommand(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\ncommands:\n"+
+			"  export-billing  export billing history as csv or ndjson\n"+
+			"  migrate         apply, roll back or list schema migrations\n"+
+			"  config print    show the effective configuration\n", name)
+		os.Exit(2)
+	}
+}
+
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+// configCommand runs "config print", which writes the effective
+// configuration as YAML with secrets redacted, then reports whether it is
+// valid.
+func configCommand(args []string) {
+	if len(args) != 1 || args[0] != "print" {
+		log.Fatal("usage: config print")
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(out)
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+}
+
+func exportBillingCommand(args []string) {
+	fs := flag.NewFlagSet("export-billing", flag.ExitOnError)
+	from := fs.String("from", "", "start of the range, inclusive (YYYY-MM-DD or RFC3339)")
+	to := fs.String("to", "", "end of the range, exclusive (YYYY-MM-DD or RFC3339)")
+	format := fs.String("format", req.ExportCSV, "output format: csv or ndjson")
+	out := fs.String("out", "-", "output file, - for stdout")
+	fs.Parse(args)
+
+	export, err := req.ParseBillingExport(*from, *to, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := exportBilling(export, *out); err != nil {
+		log.Fatalf("Failed to export billing history: %v", err)
+	}
+}
+
+// exportBilling writes the export to the file out, or to stdout for "-". A
+// file left incomplete by a failed export is removed.
+func exportBilling(export *req.BillingExport, out string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	database := config.InitDB(loadConfig().Database, nil)
+	defer database.Close()
+	logger := config.NewCLILogger()
+	defer logger.Sync()
+
+	billingService := service.NewBillingService(repository.NewBillingRepo(database, logger), logger)
+	if out == "-" {
+		return billingService.ExportBillingHistoryService(ctx, export, os.Stdout)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	err = billingService.ExportBillingHistoryService(ctx, export, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+	}
+	return err
+}
+
+// migrateCommand runs "migrate up", "migrate down [-steps N]" or
+// "migrate status" against DATABASELINK.
+func migrateCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: migrate up | down [-steps N] | status")
+	}
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	fs.Parse(args[1:])
+
+	database := config.InitDB(loadConfig().Database, nil)
+	defer database.Close()
+	logger := config.NewCLILogger()
+	defer logger.Sync()
+
+	migrator, err := migration.New(database, logger)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		fmt.Printf("applied %d migrations\n", n)
+	case "down":
+		if *steps < 1 {
+			log.Fatal("-steps must be at least 1")
+		}
+		n, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			log.Fatalf("Failed to roll back: %v", err)
+		}
+		fmt.Printf("rolled back %d migrations\n", n)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		fmt.Printf("%-8s %-20s %s\n", "version", "name", "applied_at")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-8d %-20s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
@@ -0,0 +1,445 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the app can be tuned with. Values come from Default,
+// then the optional YAML file named by CONFIGFILE, then environment
+// variables, each overriding the one before.
+type Config struct {
+	HTTP              HTTPConfig      `yaml:"http"`
+	Database          DatabaseConfig  `yaml:"database"`
+	Log               LogConfig       `yaml:"log"`
+	Admin             AdminConfig     `yaml:"admin"`
+	Scheduler         SchedulerConfig `yaml:"scheduler"`
+	Billing           BillingConfig   `yaml:"billing"`
+	Invoice           InvoiceConfig   `yaml:"invoice"`
+	Tracing           TracingConfig   `yaml:"tracing"`
+	ExchangeRatesFile string          `yaml:"exchange_rates_file"`
+	ShutdownTimeout   time.Duration   `yaml:"shutdown_timeout"`
+}
+
+type HTTPConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type DatabaseConfig struct {
+	URL         string `yaml:"url"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
+}
+
+type LogConfig struct {
+	// Level is the minimum level logged at start: debug, info, warn or
+	// error. It can be changed at runtime through the admin API.
+	Level string `yaml:"level"`
+	// Format is the encoding of stdout and stderr, console or json. The
+	// log file is always json.
+	Format string `yaml:"format"`
+	// Outputs lists where logs go: stdout, stderr and/or file.
+	Outputs []string `yaml:"outputs"`
+	Path    string   `yaml:"path"`
+	// The file is rotated when it reaches MaxSizeMB and, if RotateEvery is
+	// set, on that interval. Rotated files are removed once there are more
+	// than MaxBackups of them or they are older than MaxAgeDays; 0 keeps
+	// them.
+	MaxSizeMB   int             `yaml:"max_size_mb"`
+	RotateEvery time.Duration   `yaml:"rotate_every"`
+	MaxBackups  int             `yaml:"max_backups"`
+	MaxAgeDays  int             `yaml:"max_age_days"`
+	Compress    bool            `yaml:"compress"`
+	Access      AccessLogConfig `yaml:"access"`
+}
+
+type AccessLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SampleRate is the share of successful requests logged, from 0 to 1.
+	// Requests answered with 4xx or 5xx are always logged.
+	SampleRate float64 `yaml:"sample_rate"`
+	// Exclude lists paths that are never logged, such as health checks.
+	Exclude []string `yaml:"exclude"`
+}
+
+type AdminConfig struct {
+	// Token guards /api/admin. Empty disables the admin API.
+	Token string `yaml:"token"`
+}
+
+type SchedulerConfig struct {
+	Workers int           `yaml:"workers"`
+	Tick    time.Duration `yaml:"tick"`
+	// BatchSize above 0 switches to set-based batch billing.
+	BatchSize int `yaml:"batch_size"`
+	// ReadyWindow is how long the scheduler may go without finishing a
+	// cycle before /readyz fails. 0 means three ticks.
+	ReadyWindow time.Duration `yaml:"ready_window"`
+}
+
+// Window returns the effective ReadyWindow.
+func (s SchedulerConfig) Window() time.Duration {
+	if s.ReadyWindow > 0 {
+		return s.ReadyWindow
+	}
+	return 3 * s.Tick
+}
+
+type BillingConfig struct {
+	// Interval is how often an active client is charged one hour of usage.
+	Interval time.Duration `yaml:"interval"`
+	// LowBalanceBps is the share of the monthly fee, in basis points, below
+	// which a client's balance is reported as low.
+	LowBalanceBps int `yaml:"low_balance_bps"`
+	// ChargeTimeout bounds one charge transaction. A charge in progress is
+	// not cancelled by shutdown, so this is what keeps a stuck one from
+	// holding the process open.
+	ChargeTimeout time.Duration `yaml:"charge_timeout"`
+}
+
+type InvoiceConfig struct {
+	// Interval is how often the invoice worker looks for finished months.
+	Interval time.Duration `yaml:"interval"`
+}
+
+const (
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+
+	LogOutputStdout = "stdout"
+	LogOutputStderr = "stderr"
+	LogOutputFile   = "file"
+)
+
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp.
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+	// File makes the stdout exporter write to a file instead.
+	File string `yaml:"file"`
+	// Endpoint is the OTLP/HTTP collector, host:port. Empty falls back to
+	// OTEL_EXPORTER_OTLP_ENDPOINT and then localhost:4318.
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Traces
+	// started by a caller follow the caller's decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+func Default() Config {
+	return Config{
+		HTTP:     HTTPConfig{Addr: ":8080"},
+		Database: DatabaseConfig{AutoMigrate: true},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     LogFormatConsole,
+			Outputs:    []string{LogOutputStdout, LogOutputFile},
+			Path:       "./logs/app.log",
+			MaxSizeMB:  100,
+			MaxBackups: 10,
+			MaxAgeDays: 30,
+			Access: AccessLogConfig{
+				Enabled:    true,
+				SampleRate: 1,
+				Exclude:    []string{"/healthz", "/readyz", "/metrics"},
+			},
+		},
+		Scheduler: SchedulerConfig{
+			Workers: 5,
+			Tick:    time.Minute,
+		},
+		Billing: BillingConfig{
+			Interval:      time.Hour,
+			LowBalanceBps: 1000,
+			ChargeTimeout: 10 * time.Second,
+		},
+		Invoice: InvoiceConfig{Interval: time.Hour},
+		Tracing: TracingConfig{
+			Exporter:    TracingNone,
+			ServiceName: "maxcloud-vps",
+			SampleRatio: 1,
+		},
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// Load builds the effective configuration and validates it.
+func Load() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read builds the effective configuration without validating it.
+func Read() (*Config, error) {
+	cfg := Default()
+	if path := os.Getenv("CONFIGFILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	var errs utils.ValidationErrors
+	envString("HTTPADDR", &cfg.HTTP.Addr)
+	envString("DATABASELINK", &cfg.Database.URL)
+	envBool("AUTOMIGRATE", &cfg.Database.AutoMigrate, &errs)
+	envString("LOGLEVEL", &cfg.Log.Level)
+	envString("LOGFORMAT", &cfg.Log.Format)
+	envList("LOGOUTPUTS", &cfg.Log.Outputs)
+	envString("LOGPATH", &cfg.Log.Path)
+	envInt("LOGMAXSIZEMB", &cfg.Log.MaxSizeMB, &errs)
+	envDuration("LOGROTATEEVERY", &cfg.Log.RotateEvery, &errs)
+	envInt("LOGMAXBACKUPS", &cfg.Log.MaxBackups, &errs)
+	envInt("LOGMAXAGEDAYS", &cfg.Log.MaxAgeDays, &errs)
+	envBool("LOGCOMPRESS", &cfg.Log.Compress, &errs)
+	envBool("ACCESSLOG", &cfg.Log.Access.Enabled, &errs)
+	envFloat("ACCESSLOGSAMPLERATE", &cfg.Log.Access.SampleRate, &errs)
+	envList("ACCESSLOGEXCLUDE", &cfg.Log.Access.Exclude)
+	envString("ADMINTOKEN", &cfg.Admin.Token)
+	envInt("SCHEDULERWORKERS", &cfg.Scheduler.Workers, &errs)
+	envDuration("SCHEDULERTICK", &cfg.Scheduler.Tick, &errs)
+	envInt("BILLINGBATCHSIZE", &cfg.Scheduler.BatchSize, &errs)
+	envDuration("SCHEDULERREADYWINDOW", &cfg.Scheduler.ReadyWindow, &errs)
+	envDuration("BILLINGINTERVAL", &cfg.Billing.Interval, &errs)
+	envInt("LOWBALANCEBPS", &cfg.Billing.LowBalanceBps, &errs)
+	envDuration("CHARGETIMEOUT", &cfg.Billing.ChargeTimeout, &errs)
+	envDuration("INVOICEINTERVAL", &cfg.Invoice.Interval, &errs)
+	envString("TRACINGEXPORTER", &cfg.Tracing.Exporter)
+	envString("TRACINGSERVICENAME", &cfg.Tracing.ServiceName)
+	envString("TRACINGFILE", &cfg.Tracing.File)
+	envString("OTLPENDPOINT", &cfg.Tracing.Endpoint)
+	envBool("OTLPINSECURE", &cfg.Tracing.Insecure, &errs)
+	envFloat("TRACINGSAMPLERATIO", &cfg.Tracing.SampleRatio, &errs)
+	envString("EXCHANGERATESFILE", &cfg.ExchangeRatesFile)
+	envDuration("SHUTDOWNTIMEOUT", &cfg.ShutdownTimeout, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) Validate() error {
+	var errs utils.ValidationErrors
+	if c.HTTP.Addr == "" {
+		errs.Add("http.addr", "is required")
+	}
+	if c.Database.URL == "" {
+		errs.Add("database.url", "is required, set DATABASELINK")
+	} else if _, err := pgconn.ParseConfig(c.Database.URL); err != nil {
+		errs.Add("database.url", "is not a valid PostgreSQL connection string")
+	}
+	c.Log.validate(&errs)
+	if c.Scheduler.Workers < 1 {
+		errs.Add("scheduler.workers", "must be at least 1")
+	}
+	if c.Scheduler.Tick <= 0 {
+		errs.Add("scheduler.tick", "must be positive")
+	}
+	if c.Scheduler.BatchSize < 0 {
+		errs.Add("scheduler.batch_size", "must not be negative")
+	}
+	if c.Scheduler.ReadyWindow < 0 {
+		errs.Add("scheduler.ready_window", "must not be negative")
+	}
+	if c.Billing.Interval <= 0 {
+		errs.Add("billing.interval", "must be positive")
+	}
+	if c.Billing.LowBalanceBps < 0 || c.Billing.LowBalanceBps > 10000 {
+		errs.Add("billing.low_balance_bps", "must be between 0 and 10000")
+	}
+	if c.Billing.ChargeTimeout <= 0 {
+		errs.Add("billing.charge_timeout", "must be positive")
+	}
+	if c.Invoice.Interval <= 0 {
+		errs.Add("invoice.interval", "must be positive")
+	}
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		errs.Add("tracing.exporter", "must be %s, %s or %s", TracingNone, TracingStdout, TracingOTLP)
+	}
+	if c.Tracing.Exporter != TracingNone && c.Tracing.ServiceName == "" {
+		errs.Add("tracing.service_name", "is required")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs.Add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs.Add("shutdown_timeout", "must be positive")
+	}
+	return errs.Err()
+}
+
+func (c *LogConfig) validate(errs *utils.ValidationErrors) {
+	if _, err := zapcore.ParseLevel(c.Level); err != nil {
+		errs.Add("log.level", "must be debug, info, warn or error")
+	}
+	if c.Format != LogFormatConsole && c.Format != LogFormatJSON {
+		errs.Add("log.format", "must be %s or %s", LogFormatConsole, LogFormatJSON)
+	}
+	if len(c.Outputs) == 0 {
+		errs.Add("log.outputs", "must not be empty")
+	}
+	for _, out := range c.Outputs {
+		switch out {
+		case LogOutputStdout, LogOutputStderr:
+		case LogOutputFile:
+			if c.Path == "" {
+				errs.Add("log.path", "is required when logging to a file")
+			}
+		default:
+			errs.Add("log.outputs", "unknown output %q, use stdout, stderr or file", out)
+		}
+	}
+	if c.MaxSizeMB < 1 {
+		errs.Add("log.max_size_mb", "must be at least 1")
+	}
+	if c.RotateEvery < 0 {
+		errs.Add("log.rotate_every", "must not be negative")
+	}
+	if c.MaxBackups < 0 {
+		errs.Add("log.max_backups", "must not be negative")
+	}
+	if c.MaxAgeDays < 0 {
+		errs.Add("log.max_age_days", "must not be negative")
+	}
+	if c.Access.SampleRate < 0 || c.Access.SampleRate > 1 {
+		errs.Add("log.access.sample_rate", "must be between 0 and 1")
+	}
+}
+
+// Redacted returns a copy that is safe to print: the admin token and the
+// database password are masked.
+func (c Config) Redacted() Config {
+	if c.Admin.Token != "" {
+		c.Admin.Token = redacted
+	}
+	c.Database.URL = redactDSN(c.Database.URL)
+	return c
+}
+
+const redacted = "REDACTED"
+
+// dsnPassword matches the password of a keyword/value connection string,
+// quoted or not.
+var dsnPassword = regexp.MustCompile(`(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN masks the password of a connection string in URL form, where it
+// may be in the user info or the password query parameter, or in
+// keyword/value form. A URL that does not parse is masked as a whole.
+func redactDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+func envString(name string, dst *string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		*dst = v
+	}
+}
+
+// envList reads a comma separated list, ignoring blank items.
+func envList(name string, dst *[]string) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+func envInt(name string, dst *int, errs *utils.ValidationErrors) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		errs.Add(name, "must be an integer")
+		return
+	}
+	*dst = n
+}
+
+func envFloat(name string, dst *float64, errs *utils.ValidationErrors) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		errs.Add(name, "must be a number")
+		return
+	}
+	*dst = f
+}
+
+func envBool(name string, dst *bool, errs *utils.ValidationErrors) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		errs.Add(name, "must be true or false")
+		return
+	}
+	*dst = b
+}
+
+func envDuration(name string, dst *time.Duration, errs *utils.ValidationErrors) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		errs.Add(name, "must be a duration such as 30s or 5m")
+		return
+	}
+	*dst = d
+}
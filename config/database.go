@@ -3,18 +3,23 @@ package config
 import (
 	"context"
 	"log"
-	"os"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitDB() *pgxpool.Pool {
-	dbUrl := os.Getenv("DATABASELINK")
+// InitDB connects to cfg.URL. The schema is managed by the migration package.
+// tracer, if not nil, is called around every query.
+func InitDB(cfg DatabaseConfig, tracer pgx.QueryTracer) *pgxpool.Pool {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		log.Fatalf("Invalid database URL: %v", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracer
 	var pool *pgxpool.Pool
-	var err error
 	for i := 0; i < 5; i++ {
-		pool, err = pgxpool.New(context.Background(), dbUrl)
+		pool, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 		if err == nil {
 			if err = pool.Ping(context.Background()); err == nil {
 				log.Println("Database connected successfully")
@@ -27,13 +32,5 @@ func InitDB() *pgxpool.Pool {
 	if err != nil || pool == nil {
 		log.Fatalf("Failed to connect to the database: %v", err)
 	}
-	table, err := os.ReadFile("table.sql")
-	if err != nil {
-		log.Fatalf("Failed to get query file: %v", err)
-	}
-	_, err = pool.Exec(context.Background(), string(table))
-	if err != nil {
-		log.Fatalf("Failed to create table query: %v", err)
-	}
 	return pool
 }
@@ -1,13 +1,24 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func NewLogger() *zap.Logger {
+// NewLogger builds the app logger from cfg. The returned level controls
+// every output and can be changed while the app runs.
+func NewLogger(cfg LogConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zap.ParseAtomicLevel(cfg.Level)
+	if err != nil {
+		return nil, level, fmt.Errorf("invalid log level: %w", err)
+	}
+
 	consoleEncoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
 		LevelKey:       "level",
@@ -36,21 +47,83 @@ func NewLogger() *zap.Logger {
 	}
 	fileEncoder := zapcore.NewJSONEncoder(fileEncoderConfig)
 
-	logFile, err := os.OpenFile("./logs/app.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		panic("Failed to open log file: " + err.Error())
+	streamEncoder := consoleEncoder
+	if cfg.Format == LogFormatJSON {
+		streamEncoder = fileEncoder
 	}
 
-	core := zapcore.NewTee(
-		zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), zapcore.DebugLevel),
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
-	)
+	var cores []zapcore.Core
+	for _, out := range cfg.Outputs {
+		switch out {
+		case LogOutputStdout:
+			cores = append(cores, zapcore.NewCore(streamEncoder, zapcore.Lock(os.Stdout), level))
+		case LogOutputStderr:
+			cores = append(cores, zapcore.NewCore(streamEncoder, zapcore.Lock(os.Stderr), level))
+		case LogOutputFile:
+			logFile, err := openLogFile(cfg)
+			if err != nil {
+				return nil, level, err
+			}
+			cores = append(cores, zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), level))
+		default:
+			return nil, level, fmt.Errorf("unknown log output %q", out)
+		}
+	}
 
-	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
-	z := logger.Sugar()
-	z.Infof("Logger initialized")
+	logger := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	logger.Info("Logger initialized", zap.Stringer("min_level", level), zap.Strings("outputs", cfg.Outputs))
 
 	zap.ReplaceGlobals(logger)
 
-	return logger
+	return logger, level, nil
+}
+
+// openLogFile opens cfg.Path for appending, creating its directory if
+// needed, and rotates it by size and, when RotateEvery is set, by time.
+func openLogFile(cfg LogConfig) (*lumberjack.Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logFile := &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}
+	// An empty write opens the file now, so a bad path fails at start
+	// instead of on the first log line.
+	if _, err := logFile.Write(nil); err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	if cfg.RotateEvery > 0 {
+		// The file stays open for the life of the process, and so does
+		// this ticker.
+		go func() {
+			ticker := time.NewTicker(cfg.RotateEvery)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := logFile.Rotate(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to rotate log file: %v\n", err)
+				}
+			}
+		}()
+	}
+	return logFile, nil
+}
+
+// NewCLILogger logs to stderr only, so command output written to stdout
+// stays clean.
+func NewCLILogger() *zap.Logger {
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "timestamp",
+		LevelKey:       "level",
+		MessageKey:     "message",
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeLevel:    zapcore.CapitalLevelEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+	core := zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(os.Stderr), zapcore.InfoLevel)
+	return zap.New(core)
 }
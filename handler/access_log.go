@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type accessEntryKey struct{}
+
+// accessEntry collects what only the inner handlers know about a request,
+// such as the matched route, so AccessLog can log it afterwards.
+type accessEntry struct {
+	route     string
+	clientID  string
+	principal string
+}
+
+func accessEntryFrom(r *http.Request) *accessEntry {
+	entry, _ := r.Context().Value(accessEntryKey{}).(*accessEntry)
+	return entry
+}
+
+// AccessLog logs one line per request with its method, route, status,
+// latency and size. Requests below 400 are kept with probability
+// sampleRate, client and server errors are always logged. Paths in exclude
+// are never logged. It wraps the router, so unmatched paths are logged too;
+// AccessRoute fills in the route of matched ones.
+func AccessLog(sampleRate float64, exclude []string, logger *zap.Logger) func(http.Handler) http.Handler {
+	skip := make(map[string]bool, len(exclude))
+	for _, path := range exclude {
+		skip[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			entry := &accessEntry{}
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+
+			level := zapcore.InfoLevel
+			switch {
+			case rec.status >= 500:
+				level = zapcore.ErrorLevel
+			case rec.status >= 400:
+				level = zapcore.WarnLevel
+			case sampleRate < 1 && rand.Float64() >= sampleRate:
+				return
+			}
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("route", entry.route),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int("bytes", rec.bytes),
+				zap.String("remote_addr", r.RemoteAddr),
+			}
+			if entry.clientID != "" {
+				fields = append(fields, zap.String("client_id", entry.clientID))
+			}
+			if entry.principal != "" {
+				fields = append(fields, zap.String("principal", entry.principal))
+			}
+			utils.Log(r.Context(), logger).Log(level, "HTTP request", fields...)
+		})
+	}
+}
+
+// AccessRoute records the matched route template and client for AccessLog.
+func AccessRoute() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if entry := accessEntryFrom(r); entry != nil {
+				entry.route = routeTemplate(r)
+				entry.clientID = mux.Vars(r)["client_id"]
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
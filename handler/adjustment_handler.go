@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type AdjustmentHandler struct {
+	service service.AdjustmentServiceImpl
+	logger  *zap.Logger
+}
+
+func NewAdjustmentHandler(service service.AdjustmentServiceImpl, logger *zap.Logger) *AdjustmentHandler {
+	return &AdjustmentHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (ah *AdjustmentHandler) ListAdjustments(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(mux.Vars(r)["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	res, err := ah.service.ListAdjustmentsService(r.Context(), clientID)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ah *AdjustmentHandler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(mux.Vars(r)["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	var input req.NewAdjustment
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), ah.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := ah.service.CreateAdjustmentService(r.Context(), clientID, &input)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
+
+func (ah *AdjustmentHandler) ReverseAdjustment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clientID, err := uuid.Parse(vars["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	transactionID, err := uuid.Parse(vars["transaction_id"])
+	if err != nil {
+		info := "adjustment not found or invalid ID"
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	var input req.AdjustmentReversal
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), ah.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), ah.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := ah.service.ReverseAdjustmentService(r.Context(), clientID, transactionID, &input)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
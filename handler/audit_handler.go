@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+type AuditHandler struct {
+	service service.AuditServiceImpl
+	logger  *zap.Logger
+}
+
+func NewAuditHandler(service service.AuditServiceImpl, logger *zap.Logger) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (ah *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := req.ParseAuditFilter(r.URL.Query())
+	if err != nil {
+		utils.Log(r.Context(), ah.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := ah.service.ListAuditEventsService(r.Context(), filter)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+type BillingHandler struct {
+	service service.BillingServiceImpl
+	logger  *zap.Logger
+}
+
+func NewBillingHandler(service service.BillingServiceImpl, logger *zap.Logger) *BillingHandler {
+	return &BillingHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (bh *BillingHandler) ExportBillingHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	export, err := req.ParseBillingExport(q.Get("from"), q.Get("to"), q.Get("format"))
+	if err != nil {
+		utils.Log(r.Context(), bh.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+
+	contentType := "text/csv"
+	if export.Format == req.ExportNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("billing_%s_%s.%s", export.From.Format("20060102"), export.To.Format("20060102"), export.Format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	// The status line is already sent, an error here can only cut the
+	// download short. The service logs it.
+	bh.service.ExportBillingHistoryService(r.Context(), export, w)
+}
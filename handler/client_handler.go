@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -27,10 +26,14 @@ func NewClientHandler(service service.ClientServiceImpl, logger *zap.Logger) *Cl
 
 func (ch *ClientHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
 	var input req.NewClient
-	err := json.NewDecoder(r.Body).Decode(&input)
-	if err != nil || r.Body == nil {
-		ch.logger.Error(utils.ErrBadRequest.Error(), zap.Error(utils.ErrBadRequest))
-		utils.JSONResponse(w, http.StatusBadRequest, err)
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), ch.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), ch.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
 		return
 	}
 	if err := ch.service.CreateClientService(r.Context(), &input); err != nil {
@@ -47,7 +50,7 @@ func (ch *ClientHandler) GetClientInfo(w http.ResponseWriter, r *http.Request) {
 	clientID, err := uuid.Parse(clientIDString)
 	if err != nil {
 		info := "id not found or invalid ID"
-		ch.logger.Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.Log(r.Context(), ch.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
 		utils.JSONResponse(w, http.StatusNotFound, err)
 		return
 	}
@@ -59,3 +62,46 @@ func (ch *ClientHandler) GetClientInfo(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.JSONResponse(w, http.StatusOK, res)
 }
+
+func (ch *ClientHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	filter, err := req.ParseClientFilter(r.URL.Query())
+	if err != nil {
+		utils.Log(r.Context(), ch.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := ch.service.ListClientsService(r.Context(), filter)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (ch *ClientHandler) UpdateClientTax(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(mux.Vars(r)["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ch.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	var input req.ClientTax
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), ch.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), ch.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	if err := ch.service.UpdateClientTaxService(r.Context(), clientID, &input); err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, input)
+}
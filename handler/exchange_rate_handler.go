@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+type ExchangeRateHandler struct {
+	service service.ExchangeRateServiceImpl
+	logger  *zap.Logger
+}
+
+func NewExchangeRateHandler(service service.ExchangeRateServiceImpl, logger *zap.Logger) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (eh *ExchangeRateHandler) ListExchangeRates(w http.ResponseWriter, r *http.Request) {
+	res, err := eh.service.ListExchangeRatesService(r.Context())
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (eh *ExchangeRateHandler) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var input req.NewExchangeRate
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), eh.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), eh.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := eh.service.CreateExchangeRateService(r.Context(), &input)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
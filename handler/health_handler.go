@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+// readinessTimeout bounds a readiness check, so a hung database makes the
+// probe fail instead of hang.
+const readinessTimeout = 3 * time.Second
+
+type HealthHandler struct {
+	service service.HealthServiceImpl
+	logger  *zap.Logger
+}
+
+func NewHealthHandler(service service.HealthServiceImpl, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Liveness only tells that the process is serving requests.
+func (hh *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, map[string]string{"status": res.StatusUp})
+}
+
+func (hh *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	health := hh.service.ReadinessService(ctx)
+	status := http.StatusOK
+	if health.Status != res.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	utils.JSONResponse(w, status, health)
+}
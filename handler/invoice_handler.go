@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+//go:embed templates/invoice.html
+var templateFS embed.FS
+
+var invoiceTemplate = template.Must(template.ParseFS(templateFS, "templates/invoice.html"))
+
+type InvoiceHandler struct {
+	service service.InvoiceServiceImpl
+	logger  *zap.Logger
+}
+
+func NewInvoiceHandler(service service.InvoiceServiceImpl, logger *zap.Logger) *InvoiceHandler {
+	return &InvoiceHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (ih *InvoiceHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	clientID, err := uuid.Parse(mux.Vars(r)["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	res, err := ih.service.ListInvoicesService(r.Context(), clientID)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+// GetInvoice responds with JSON, or with a printable HTML page when called
+// with ?format=html.
+func (ih *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	clientID, err := uuid.Parse(vars["client_id"])
+	if err != nil {
+		info := "id not found or invalid ID"
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	invoiceID, err := uuid.Parse(vars["invoice_id"])
+	if err != nil {
+		info := "invoice not found or invalid ID"
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	res, err := ih.service.GetInvoiceService(r.Context(), clientID, invoiceID)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	if r.URL.Query().Get("format") != "html" {
+		utils.JSONResponse(w, http.StatusOK, res)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := invoiceTemplate.Execute(w, res); err != nil {
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrInternal.Error(), zap.String("error", "failed to render invoice"), zap.Error(err))
+	}
+}
+
+func (ih *InvoiceHandler) UpdateInvoiceStatus(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := uuid.Parse(mux.Vars(r)["invoice_id"])
+	if err != nil {
+		info := "invoice not found or invalid ID"
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrNotFound.Error(), zap.String("error", info), zap.Error(err))
+		utils.JSONResponse(w, http.StatusNotFound, err)
+		return
+	}
+	var input req.InvoiceStatus
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), ih.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), ih.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := ih.service.UpdateInvoiceStatusService(r.Context(), invoiceID, &input)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+// LogHandler reads and changes the log level of the running app. The change
+// is not persisted, a restart goes back to the configured level.
+type LogHandler struct {
+	level  zap.AtomicLevel
+	logger *zap.Logger
+}
+
+func NewLogHandler(level zap.AtomicLevel, logger *zap.Logger) *LogHandler {
+	return &LogHandler{
+		level:  level,
+		logger: logger,
+	}
+}
+
+func (lh *LogHandler) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, req.LogLevel{Level: lh.level.String()})
+}
+
+func (lh *LogHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var input req.LogLevel
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), lh.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), lh.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	previous := lh.level.String()
+	if err := lh.level.UnmarshalText([]byte(input.Level)); err != nil {
+		utils.JSONResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.Log(r.Context(), lh.logger).Warn("Log level changed", zap.String("from", previous), zap.String("to", input.Level))
+	utils.JSONResponse(w, http.StatusOK, req.LogLevel{Level: lh.level.String()})
+}
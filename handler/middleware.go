@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/metrics"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// AdminAuth only lets requests through that carry "Authorization: Bearer <token>".
+// An empty token disables the admin API entirely.
+func AdminAuth(token string, logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				utils.Log(r.Context(), logger).Warn("admin API disabled, ADMINTOKEN is not set", zap.String("path", r.URL.Path))
+				utils.JSONResponse(w, http.StatusForbidden, "admin API is disabled")
+				return
+			}
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				utils.Log(r.Context(), logger).Warn("unauthorized admin request", zap.String("path", r.URL.Path))
+				utils.JSONResponse(w, http.StatusUnauthorized, "invalid admin token")
+				return
+			}
+			if entry := accessEntryFrom(r); entry != nil {
+				entry.principal = "admin"
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Headers an admin may send to say who they are and why they make a change.
+// Both end up in the audit log.
+const (
+	AdminUserHeader   = "X-Admin-User"
+	AuditReasonHeader = "X-Audit-Reason"
+)
+
+// maxAuditReasonLen bounds the reason an admin gives for a change.
+const maxAuditReasonLen = 500
+
+// AdminActor records admin requests as made by the admin named in
+// X-Admin-User, for the reason given in X-Audit-Reason. It runs after
+// AdminAuth, the header is only trusted from a caller holding the token.
+func AdminActor(logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := r.Header.Get(AdminUserHeader)
+			if user != "" && !validRequestID(user) {
+				utils.Log(r.Context(), logger).Warn("invalid admin user header", zap.String("path", r.URL.Path))
+				utils.JSONResponse(w, http.StatusBadRequest, AdminUserHeader+" may only contain letters, digits and -_.:")
+				return
+			}
+			reason := strings.TrimSpace(r.Header.Get(AuditReasonHeader))
+			if len(reason) > maxAuditReasonLen {
+				utils.Log(r.Context(), logger).Warn("audit reason too long", zap.String("path", r.URL.Path))
+				utils.JSONResponse(w, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d bytes", AuditReasonHeader, maxAuditReasonLen))
+				return
+			}
+			ctx := utils.WithActor(r.Context(), utils.Actor{Type: utils.ActorAdmin, ID: user})
+			if reason != "" {
+				ctx = utils.WithAuditReason(ctx, reason)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientActor records requests as made by the client in the path, or by a
+// client yet to exist for registration.
+func ClientActor() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := utils.Actor{Type: utils.ActorClient, ID: mux.Vars(r)["client_id"]}
+			next.ServeHTTP(w, r.WithContext(utils.WithActor(r.Context(), actor)))
+		})
+	}
+}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds a request ID supplied by the caller.
+const maxRequestIDLen = 128
+
+// RequestID tags every request with an ID taken from X-Request-ID, or a new
+// one when the header is missing or unusable. The ID is echoed back in the
+// response and added to every log line of the request, see utils.Log.
+func RequestID() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			ctx := utils.WithRequestID(r.Context(), id)
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				ctx = utils.WithLogFields(ctx, zap.String("trace_id", sc.TraceID().String()))
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID accepts short IDs made of letters, digits and -_.: so a
+// caller can't inject arbitrary text into logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// untracedPaths are polled by the orchestrator and Prometheus and would
+// drown real traffic in traces.
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Traced is the otelhttp filter that decides which requests get a span.
+func Traced(r *http.Request) bool {
+	return !untracedPaths[r.URL.Path]
+}
+
+// TraceRoute names the request span after the matched route template and
+// tags it with the request ID.
+func TraceRoute() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			if route := routeTemplate(r); route != "" {
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(semconv.HTTPRoute(route))
+			}
+			span.SetAttributes(attribute.String("request_id", utils.RequestID(r.Context())))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeTemplate returns the template of the route mux matched, or "".
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return ""
+}
+
+// Metrics records the latency and status of every request under its route
+// template, so /api/client/{client_id} is one series rather than one per
+// client.
+func Metrics() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			route := routeTemplate(r)
+			if route == "" {
+				route = "unknown"
+			}
+			metrics.HTTPRequest(route, r.Method, rec.status, time.Since(start))
+		})
+	}
+}
+
+// statusRecorder remembers the status code and body size written by a
+// handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Flush forwards to the underlying writer, so handlers that stream, such as
+// the billing export, keep flushing through Metrics and AccessLog.
+func (sr *statusRecorder) Flush() {
+	http.NewResponseController(sr.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
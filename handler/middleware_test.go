@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// The billing export flushes every few rows; the recording middlewares must
+// not hide Flush from it.
+func TestRecordingMiddlewaresFlush(t *testing.T) {
+	h := Metrics()(AccessLog(1, nil, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(interface{ Flush() })
+		if !ok {
+			t.Fatal("response writer has no Flush method")
+		}
+		f.Flush()
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/billing/export", nil))
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying response writer")
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+type TaxHandler struct {
+	service service.TaxServiceImpl
+	logger  *zap.Logger
+}
+
+func NewTaxHandler(service service.TaxServiceImpl, logger *zap.Logger) *TaxHandler {
+	return &TaxHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (th *TaxHandler) ListTaxRules(w http.ResponseWriter, r *http.Request) {
+	res, err := th.service.ListTaxRulesService(r.Context())
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, res)
+}
+
+func (th *TaxHandler) CreateTaxRule(w http.ResponseWriter, r *http.Request) {
+	var input req.NewTaxRule
+	if err := utils.DecodeJSON(w, r, &input); err != nil {
+		utils.Log(r.Context(), th.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err.Error())
+		return
+	}
+	if err := input.Validate(); err != nil {
+		utils.Log(r.Context(), th.logger).Warn(utils.ErrValidation.Error(), zap.Error(err))
+		utils.JSONResponse(w, utils.ErrCheck(err), err)
+		return
+	}
+	res, err := th.service.CreateTaxRuleService(r.Context(), &input)
+	if err != nil {
+		status := utils.ErrCheck(err)
+		utils.JSONResponse(w, status, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, res)
+}
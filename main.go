@@ -2,41 +2,224 @@ package main
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/bagasadiii/maxcloud_vps/config"
 	"github.com/bagasadiii/maxcloud_vps/handler"
+	"github.com/bagasadiii/maxcloud_vps/metrics"
+	"github.com/bagasadiii/maxcloud_vps/migration"
 	"github.com/bagasadiii/maxcloud_vps/repository"
 	"github.com/bagasadiii/maxcloud_vps/service"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.uber.org/zap"
 )
 
 func main() {
 	godotenv.Load(".env")
-	database := config.InitDB()
-	logger := config.NewLogger()
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	logger, logLevel, err := config.NewLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	database := config.InitDB(cfg.Database, tracing.QueryTracer{})
+
+	migrator, err := migration.New(database, logger)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", zap.Error(err))
+	}
+	if cfg.Database.AutoMigrate {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			logger.Fatal("Failed to migrate database", zap.Error(err))
+		}
+	}
 
 	clientRepo := repository.NewClientRepo(database, logger)
-	clientService := service.NewClientService(clientRepo, logger)
+	clock := utils.RealClock{}
+	transactor := repository.NewTransactor(database, logger)
+
+	clientService := service.NewClientService(clientRepo, clock, logger)
 	clientHandler := handler.NewClientHandler(clientService, logger)
 
-	txSchedulerRepo := repository.NewTransactionSchedulerRepo(database, logger)
-	txSchedulerService := service.NewTransactionSchedulerService(database, txSchedulerRepo, logger)
+	billingRepo := repository.NewBillingRepo(database, logger)
+	billingService := service.NewBillingService(billingRepo, logger)
+	billingHandler := handler.NewBillingHandler(billingService, logger)
+
+	invoiceRepo := repository.NewInvoiceRepo(database, clock, logger)
+	invoiceService := service.NewInvoiceService(transactor, invoiceRepo, clock, logger)
+	invoiceHandler := handler.NewInvoiceHandler(invoiceService, logger)
+
+	taxRepo := repository.NewTaxRepo(database, logger)
+	taxService := service.NewTaxService(taxRepo, clock, logger)
+	taxHandler := handler.NewTaxHandler(taxService, logger)
+
+	exchangeRateRepo := repository.NewExchangeRateRepo(database, logger)
+	exchangeRateService := service.NewExchangeRateService(exchangeRateRepo, clock, logger)
+	exchangeRateHandler := handler.NewExchangeRateHandler(exchangeRateService, logger)
+	if path := cfg.ExchangeRatesFile; path != "" {
+		if _, err := exchangeRateService.LoadExchangeRatesFile(context.Background(), path); err != nil {
+			logger.Fatal("Failed to load exchange rates", zap.String("file", path), zap.Error(err))
+		}
+	}
+
+	logHandler := handler.NewLogHandler(logLevel, logger)
+
+	auditRepo := repository.NewAuditRepo(database, logger)
+	auditService := service.NewAuditService(auditRepo, logger)
+	auditHandler := handler.NewAuditHandler(auditService, logger)
+
+	txSchedulerRepo := repository.NewTransactionSchedulerRepo(database, clock, logger)
+	txSchedulerService := service.NewTransactionSchedulerService(transactor, txSchedulerRepo, taxService, exchangeRateService, cfg.Billing, clock, logger)
+
+	adjustmentRepo := repository.NewAdjustmentRepo(database, logger)
+	adjustmentService := service.NewAdjustmentService(transactor, adjustmentRepo, txSchedulerRepo, exchangeRateService, clock, logger)
+	adjustmentHandler := handler.NewAdjustmentHandler(adjustmentService, logger)
+
+	healthService := service.NewHealthService(database, migrator, txSchedulerService, cfg.Scheduler.Window(), clock, logger)
+	healthHandler := handler.NewHealthHandler(healthService, logger)
+
+	if err := metrics.RegisterPool(database); err != nil {
+		logger.Fatal("Failed to register pool metrics", zap.Error(err))
+	}
 
 	r := mux.NewRouter()
+	r.Use(handler.Metrics(), handler.TraceRoute(), handler.AccessRoute())
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", healthHandler.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readiness).Methods("GET")
 
-	r.HandleFunc("/api/register", clientHandler.CreateClient).Methods("POST")
-	r.HandleFunc("/api/client/{client_id}", clientHandler.GetClientInfo).Methods("GET")
+	r.Handle("/api/register", handler.ClientActor()(http.HandlerFunc(clientHandler.CreateClient))).Methods("POST")
+	client := r.PathPrefix("/api/client/{client_id}").Subrouter()
+	client.Use(handler.ClientActor())
+	client.HandleFunc("", clientHandler.GetClientInfo).Methods("GET")
+	client.HandleFunc("/invoices", invoiceHandler.ListInvoices).Methods("GET")
+	client.HandleFunc("/invoices/{invoice_id}", invoiceHandler.GetInvoice).Methods("GET")
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go txSchedulerService.SchedulerWorkerService(ctx, 5)
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(handler.AdminAuth(cfg.Admin.Token, logger), handler.AdminActor(logger))
+	admin.HandleFunc("/clients", clientHandler.ListClients).Methods("GET")
+	admin.HandleFunc("/clients/{client_id}/tax", clientHandler.UpdateClientTax).Methods("PUT")
+	admin.HandleFunc("/clients/{client_id}/adjustments", adjustmentHandler.ListAdjustments).Methods("GET")
+	admin.HandleFunc("/clients/{client_id}/adjustments", adjustmentHandler.CreateAdjustment).Methods("POST")
+	admin.HandleFunc("/clients/{client_id}/adjustments/{transaction_id}/reverse", adjustmentHandler.ReverseAdjustment).Methods("POST")
+	admin.HandleFunc("/tax-rules", taxHandler.ListTaxRules).Methods("GET")
+	admin.HandleFunc("/tax-rules", taxHandler.CreateTaxRule).Methods("POST")
+	admin.HandleFunc("/exchange-rates", exchangeRateHandler.ListExchangeRates).Methods("GET")
+	admin.HandleFunc("/exchange-rates", exchangeRateHandler.CreateExchangeRate).Methods("POST")
+	admin.HandleFunc("/billing/export", billingHandler.ExportBillingHistory).Methods("GET")
+	admin.HandleFunc("/invoices/{invoice_id}/status", invoiceHandler.UpdateInvoiceStatus).Methods("PUT")
+	admin.HandleFunc("/log-level", logHandler.GetLogLevel).Methods("GET")
+	admin.HandleFunc("/log-level", logHandler.SetLogLevel).Methods("PUT")
+	admin.HandleFunc("/audit-events", auditHandler.ListAuditEvents).Methods("GET")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		scheduler := cfg.Scheduler
+		if scheduler.BatchSize > 0 {
+			txSchedulerService.BatchSchedulerService(workerCtx, scheduler.Workers, scheduler.Tick, scheduler.BatchSize)
+		} else {
+			txSchedulerService.SchedulerWorkerService(workerCtx, scheduler.Workers, scheduler.Tick)
+		}
+	}()
+	go func() {
+		defer workers.Done()
+		invoiceService.InvoiceWorkerService(workerCtx, cfg.Invoice.Interval)
+	}()
+
+	var root http.Handler = r
+	if access := cfg.Log.Access; access.Enabled {
+		root = handler.AccessLog(access.SampleRate, access.Exclude, logger)(root)
+	}
+	root = otelhttp.NewHandler(handler.RequestID()(root), "http.server", otelhttp.WithFilter(handler.Traced))
 
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+		Addr:    cfg.HTTP.Addr,
+		Handler: root,
 	}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("HTTP server listening", zap.String("addr", server.Addr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
 
-	server.ListenAndServe()
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutdown signal received")
+	case err := <-serverErr:
+		logger.Error("HTTP server failed", zap.Error(err))
+	}
+	if shutdown(logger, server, stopWorkers, &workers, cfg.ShutdownTimeout) {
+		// Close waits for every connection to be released, which whatever
+		// is still running may never do.
+		logger.Warn("Leaving database connections open")
+	} else {
+		database.Close()
+	}
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Warn("Failed to flush traces", zap.Error(err))
+	}
+	cancelFlush()
+	logger.Info("Shutdown complete")
+	logger.Sync()
+}
+
+// shutdown stops accepting requests, drains in-flight ones, then stops the
+// background workers and waits for running charges to commit or roll back.
+// Whatever is still running after timeout is abandoned, and timedOut reports
+// whether anything was.
+func shutdown(logger *zap.Logger, server *http.Server, stopWorkers context.CancelFunc, workers *sync.WaitGroup, timeout time.Duration) (timedOut bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("HTTP server did not drain in time", zap.Error(err))
+		timedOut = true
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("Workers did not stop in time", zap.Duration("timeout", timeout))
+		timedOut = true
+	}
+	return timedOut
 }
@@ -0,0 +1,112 @@
+// Package metrics holds the Prometheus collectors of the app. They are
+// registered on the default registry and served by promhttp.Handler.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "maxcloud"
+
+// Charge results.
+const (
+	ChargeApplied = "applied"
+	ChargeSkipped = "skipped"
+	ChargeFailed  = "failed"
+)
+
+// Charge modes, matching the two scheduler implementations.
+const (
+	ModeSingle = "single"
+	ModeBatch  = "batch"
+)
+
+var (
+	charges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "charges_total",
+		Help:      "Hourly charges by result. A failed batch counts once.",
+	}, []string{"mode", "result"})
+
+	billed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "billed_amount_total",
+		Help:      "Amount charged before tax, in minor units of the currency.",
+	}, []string{"plan", "currency"})
+
+	suspensions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suspensions_total",
+		Help:      "Clients suspended because their balance went negative.",
+	}, []string{"plan"})
+
+	registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Clients registered.",
+	}, []string{"plan", "currency"})
+
+	chargeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "charge_duration_seconds",
+		Help:      "Time spent in one charge transaction, a single client or a whole batch.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"mode"})
+
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_queue_depth",
+		Help:      "Clients queued for the scheduler workers.",
+	})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// Charged records a charge of amount, before tax, to a client on plan.
+func Charged(mode, plan string, amount model.Money) {
+	charges.WithLabelValues(mode, ChargeApplied).Inc()
+	billed.WithLabelValues(plan, string(amount.Currency)).Add(float64(amount.Amount))
+}
+
+// ChargeResult records a charge that did not apply, result is ChargeSkipped
+// or ChargeFailed.
+func ChargeResult(mode, result string) {
+	charges.WithLabelValues(mode, result).Inc()
+}
+
+func ChargeDuration(mode string, took time.Duration) {
+	chargeDuration.WithLabelValues(mode).Observe(took.Seconds())
+}
+
+func Suspended(plan string) {
+	suspensions.WithLabelValues(plan).Inc()
+}
+
+func Registered(plan string, currency model.Currency) {
+	registrations.WithLabelValues(plan, string(currency)).Inc()
+}
+
+func QueueDepth(n int) {
+	queueDepth.Set(float64(n))
+}
+
+func HTTPRequest(route, method string, code int, took time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(took.Seconds())
+}
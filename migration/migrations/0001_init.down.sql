@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS billings;
+DROP TABLE IF EXISTS clients;
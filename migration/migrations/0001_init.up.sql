@@ -6,7 +6,7 @@ CREATE TABLE IF NOT EXISTS clients (
   created_at TIMESTAMPTZ,
   updated_at TIMESTAMPTZ
 );
-CREATE TABLE IF NOT EXISTS billings ( 
+CREATE TABLE IF NOT EXISTS billings (
   billing_id UUID PRIMARY KEY,
   client_id UUID NOT NULL,
   cpu INT NOT NULL,
@@ -20,4 +20,3 @@ CREATE TABLE IF NOT EXISTS billings (
   updated_at TIMESTAMPTZ,
   CONSTRAINT fk_billing_client FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE
 );
-
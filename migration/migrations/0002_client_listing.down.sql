@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_billings_client_id;
+DROP INDEX IF EXISTS idx_clients_suspended;
+DROP INDEX IF EXISTS idx_clients_plan;
+DROP INDEX IF EXISTS idx_clients_balance;
+DROP INDEX IF EXISTS idx_clients_created_at;
+
+ALTER TABLE clients DROP COLUMN IF EXISTS plan;
@@ -0,0 +1,7 @@
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS plan VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_clients_created_at ON clients (created_at, client_id);
+CREATE INDEX IF NOT EXISTS idx_clients_balance ON clients (balance, client_id);
+CREATE INDEX IF NOT EXISTS idx_clients_plan ON clients (plan);
+CREATE INDEX IF NOT EXISTS idx_clients_suspended ON clients (suspended);
+CREATE INDEX IF NOT EXISTS idx_billings_client_id ON billings (client_id);
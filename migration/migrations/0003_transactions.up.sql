@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS transactions (
+  transaction_id UUID PRIMARY KEY,
+  client_id UUID NOT NULL,
+  billing_id UUID NOT NULL,
+  type VARCHAR(20) NOT NULL,
+  amount INT NOT NULL,
+  balance_after INT NOT NULL,
+  period_start TIMESTAMPTZ,
+  period_end TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_transaction_client FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE,
+  CONSTRAINT fk_transaction_billing FOREIGN KEY (billing_id) REFERENCES billings(billing_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at, transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_client_id ON transactions (client_id, created_at);
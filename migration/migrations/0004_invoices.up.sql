@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS invoice_counter (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  last_number BIGINT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS invoices (
+  invoice_id UUID PRIMARY KEY,
+  invoice_number VARCHAR(30) UNIQUE NOT NULL,
+  client_id UUID NOT NULL,
+  billing_id UUID NOT NULL,
+  period_start TIMESTAMPTZ NOT NULL,
+  period_end TIMESTAMPTZ NOT NULL,
+  status VARCHAR(10) NOT NULL DEFAULT 'open',
+  total INT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT uq_invoice_period UNIQUE (billing_id, period_start),
+  CONSTRAINT chk_invoice_status CHECK (status IN ('open', 'paid', 'void')),
+  CONSTRAINT fk_invoice_client FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE,
+  CONSTRAINT fk_invoice_billing FOREIGN KEY (billing_id) REFERENCES billings(billing_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_invoices_client_id ON invoices (client_id, period_start);
+CREATE TABLE IF NOT EXISTS invoice_lines (
+  line_id UUID PRIMARY KEY,
+  invoice_id UUID NOT NULL,
+  type VARCHAR(20) NOT NULL,
+  description TEXT NOT NULL,
+  quantity INT NOT NULL,
+  unit_price INT NOT NULL,
+  amount INT NOT NULL,
+  CONSTRAINT fk_line_invoice FOREIGN KEY (invoice_id) REFERENCES invoices(invoice_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice_id ON invoice_lines (invoice_id);
@@ -0,0 +1,9 @@
+ALTER TABLE invoice_lines DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_total;
+ALTER TABLE invoices DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE clients DROP COLUMN IF EXISTS tax_exempt;
+ALTER TABLE clients DROP COLUMN IF EXISTS tax_id;
+
+DROP TABLE IF EXISTS tax_rules;
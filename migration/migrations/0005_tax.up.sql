@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS tax_rules (
+  rule_id UUID PRIMARY KEY,
+  name VARCHAR(20) NOT NULL,
+  rate_bps INT NOT NULL CHECK (rate_bps BETWEEN 0 AND 10000),
+  effective_from TIMESTAMPTZ NOT NULL,
+  effective_to TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT uq_tax_rule UNIQUE (name, effective_from)
+);
+INSERT INTO tax_rules (rule_id, name, rate_bps, effective_from, created_at)
+VALUES ('8d0c6f5e-2f43-4d3a-9a57-4f0b3c7e2a11', 'PPN', 1100, '2022-04-01T00:00:00+07:00', NOW())
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tax_id VARCHAR(16);
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tax_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tax_amount INT NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_id VARCHAR(16);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS subtotal INT NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_total INT NOT NULL DEFAULT 0;
+ALTER TABLE invoice_lines ADD COLUMN IF NOT EXISTS tax_amount INT NOT NULL DEFAULT 0;
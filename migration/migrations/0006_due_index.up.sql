@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_clients_due ON clients (updated_at) WHERE suspended = false;
@@ -0,0 +1,8 @@
+ALTER TABLE billings ALTER COLUMN updated_at DROP NOT NULL;
+ALTER TABLE billings ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE billings ALTER COLUMN updated_at DROP DEFAULT;
+ALTER TABLE billings ALTER COLUMN created_at DROP DEFAULT;
+ALTER TABLE clients ALTER COLUMN updated_at DROP DEFAULT;
+ALTER TABLE clients ALTER COLUMN created_at DROP DEFAULT;
+
+ALTER TABLE billings DROP COLUMN IF EXISTS down_payment;
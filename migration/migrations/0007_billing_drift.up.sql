@@ -0,0 +1,34 @@
+ALTER TABLE billings ADD COLUMN IF NOT EXISTS down_payment INT NOT NULL DEFAULT 0;
+
+-- Clients created before the plan column existed are matched to their plan by
+-- the resources they were given.
+UPDATE clients c SET plan = CASE
+    WHEN b.cpu = 1 THEN 'basic'
+    WHEN b.cpu = 2 THEN 'normal'
+    WHEN b.cpu = 4 THEN 'premium'
+  END
+FROM billings b
+WHERE b.client_id = c.client_id AND c.plan IS NULL;
+
+UPDATE billings b SET down_payment = CASE c.plan
+    WHEN 'basic' THEN 15000
+    WHEN 'normal' THEN 25000
+    WHEN 'premium' THEN 40000
+    ELSE 0
+  END
+FROM clients c
+WHERE c.client_id = b.client_id AND b.down_payment = 0;
+
+UPDATE billings b SET created_at = c.created_at
+FROM clients c
+WHERE c.client_id = b.client_id AND b.created_at IS NULL;
+UPDATE billings SET updated_at = created_at WHERE updated_at IS NULL OR updated_at < created_at;
+
+ALTER TABLE clients ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE clients ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE billings ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE billings ALTER COLUMN updated_at SET DEFAULT NOW();
+UPDATE billings SET created_at = NOW() WHERE created_at IS NULL;
+UPDATE billings SET updated_at = NOW() WHERE updated_at IS NULL;
+ALTER TABLE billings ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE billings ALTER COLUMN updated_at SET NOT NULL;
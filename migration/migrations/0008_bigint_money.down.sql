@@ -0,0 +1,19 @@
+-- Fails if any amount no longer fits in INT.
+ALTER TABLE invoice_lines
+  ALTER COLUMN unit_price TYPE INT,
+  ALTER COLUMN amount TYPE INT,
+  ALTER COLUMN tax_amount TYPE INT;
+ALTER TABLE invoices
+  ALTER COLUMN subtotal TYPE INT,
+  ALTER COLUMN tax_total TYPE INT,
+  ALTER COLUMN total TYPE INT;
+ALTER TABLE transactions
+  ALTER COLUMN amount TYPE INT,
+  ALTER COLUMN tax_amount TYPE INT,
+  ALTER COLUMN balance_after TYPE INT;
+ALTER TABLE billings
+  ALTER COLUMN down_payment TYPE INT,
+  ALTER COLUMN monthly_fee TYPE INT,
+  ALTER COLUMN cost_per_hour TYPE INT,
+  ALTER COLUMN total_fee TYPE INT;
+ALTER TABLE clients ALTER COLUMN balance TYPE INT;
@@ -0,0 +1,18 @@
+ALTER TABLE clients ALTER COLUMN balance TYPE BIGINT;
+ALTER TABLE billings
+  ALTER COLUMN down_payment TYPE BIGINT,
+  ALTER COLUMN monthly_fee TYPE BIGINT,
+  ALTER COLUMN cost_per_hour TYPE BIGINT,
+  ALTER COLUMN total_fee TYPE BIGINT;
+ALTER TABLE transactions
+  ALTER COLUMN amount TYPE BIGINT,
+  ALTER COLUMN tax_amount TYPE BIGINT,
+  ALTER COLUMN balance_after TYPE BIGINT;
+ALTER TABLE invoices
+  ALTER COLUMN subtotal TYPE BIGINT,
+  ALTER COLUMN tax_total TYPE BIGINT,
+  ALTER COLUMN total TYPE BIGINT;
+ALTER TABLE invoice_lines
+  ALTER COLUMN unit_price TYPE BIGINT,
+  ALTER COLUMN amount TYPE BIGINT,
+  ALTER COLUMN tax_amount TYPE BIGINT;
@@ -0,0 +1,6 @@
+ALTER TABLE invoices DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS exchange_rate_micros;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE clients DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS exchange_rates;
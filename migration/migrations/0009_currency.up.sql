@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+  rate_id UUID PRIMARY KEY,
+  currency VARCHAR(3) NOT NULL,
+  rate_micros BIGINT NOT NULL CHECK (rate_micros > 0),
+  effective_from TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT uq_exchange_rate UNIQUE (currency, effective_from)
+);
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
+-- Rupiah per unit of currency at the time of the charge, in millionths.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS exchange_rate_micros BIGINT NOT NULL DEFAULT 1000000;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
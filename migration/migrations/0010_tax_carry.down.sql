@@ -0,0 +1 @@
+ALTER TABLE billings DROP COLUMN IF EXISTS tax_carry;
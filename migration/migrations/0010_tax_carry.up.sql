@@ -0,0 +1,4 @@
+-- Tax owed below the smallest currency unit, in ten-thousandths of it. It is
+-- carried into the next hourly charge instead of being rounded away, so tax
+-- on many small charges adds up to the tax on their total.
+ALTER TABLE billings ADD COLUMN IF NOT EXISTS tax_carry BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+-- Who changed what and why. Rows are only ever inserted.
+CREATE TABLE IF NOT EXISTS audit_events (
+  event_id UUID PRIMARY KEY,
+  occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  actor_type VARCHAR(20) NOT NULL,
+  actor_id TEXT NOT NULL DEFAULT '',
+  action VARCHAR(50) NOT NULL,
+  entity_type VARCHAR(30) NOT NULL,
+  entity_id TEXT NOT NULL,
+  client_id UUID,
+  before JSONB,
+  after JSONB,
+  reason TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred ON audit_events (occurred_at DESC, event_id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_client ON audit_events (client_id, occurred_at DESC);
@@ -0,0 +1,19 @@
+-- Manual balance changes made by admins. Each one is also a transaction of
+-- type adjustment; reverses points at the adjustment a reversal undoes, and
+-- is unique so an adjustment is reversed at most once.
+CREATE TABLE IF NOT EXISTS adjustments (
+  transaction_id UUID PRIMARY KEY,
+  client_id UUID NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  amount BIGINT NOT NULL,
+  reason_code VARCHAR(20) NOT NULL,
+  note TEXT NOT NULL DEFAULT '',
+  reverses UUID UNIQUE,
+  actor_type VARCHAR(20) NOT NULL,
+  actor_id TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT fk_adjustment_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(transaction_id) ON DELETE CASCADE,
+  CONSTRAINT fk_adjustment_client FOREIGN KEY (client_id) REFERENCES clients(client_id) ON DELETE CASCADE,
+  CONSTRAINT fk_adjustment_reverses FOREIGN KEY (reverses) REFERENCES adjustments(transaction_id)
+);
+CREATE INDEX IF NOT EXISTS idx_adjustments_client ON adjustments (client_id, created_at);
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+// Adjustment reason codes. AdjustmentReversal is only set on reversals.
+const (
+	AdjustmentOutageCredit = "outage_credit"
+	AdjustmentMischarge    = "mischarge"
+	AdjustmentGoodwill     = "goodwill"
+	AdjustmentOther        = "other"
+	AdjustmentReversal     = "reversal"
+)
+
+// Adjustment is a balance change an admin made by hand. Amount is added to
+// the balance, negative to take money off. It is recorded as the transaction
+// TransactionID, and is undone by a reversal adjustment of the opposite
+// amount rather than by deleting it.
+type Adjustment struct {
+	TransactionID uuid.UUID   `json:"transaction_id"`
+	ClientID      uuid.UUID   `json:"client_id"`
+	Amount        Money       `json:"amount"`
+	BalanceAfter  Money       `json:"balance_after"`
+	ReasonCode    string      `json:"reason_code"`
+	Note          string      `json:"note,omitempty"`
+	Reverses      *uuid.UUID  `json:"reverses,omitempty"`
+	ReversedBy    *uuid.UUID  `json:"reversed_by,omitempty"`
+	CreatedBy     utils.Actor `json:"created_by"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+// Audit actions.
+const (
+	AuditClientCreated       = "client.created"
+	AuditBalanceChanged      = "client.balance_changed"
+	AuditClientSuspended     = "client.suspended"
+	AuditClientTaxChanged    = "client.tax_changed"
+	AuditInvoiceStatus       = "invoice.status_changed"
+	AuditTaxRuleCreated      = "tax_rule.created"
+	AuditExchangeRateCreated = "exchange_rate.created"
+)
+
+// Audit reasons set by the system. Admin actions carry the reason the admin
+// gives.
+const (
+	ReasonRegistration    = "registration"
+	ReasonHourlyCharge    = "hourly_charge"
+	ReasonNegativeBalance = "negative_balance"
+)
+
+// AuditEvent records one change. Before and After hold the changed fields
+// only; Before is empty for creations.
+type AuditEvent struct {
+	EventID    uuid.UUID   `json:"event_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Actor      utils.Actor `json:"actor"`
+	Action     string      `json:"action"`
+	EntityType string      `json:"entity_type"`
+	EntityID   string      `json:"entity_id"`
+	ClientID   *uuid.UUID  `json:"client_id,omitempty"`
+	Before     any         `json:"before,omitempty"`
+	After      any         `json:"after,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
+}
@@ -5,29 +5,45 @@ import (
 
 	"github.com/google/uuid"
 )
+
 type Billing struct {
 	BillingID   uuid.UUID `json:"billing_id"`
 	CPU         int       `json:"cpu"`
 	RAM         int       `json:"ram"`
 	Storage     int       `json:"storage"`
-	DownPayment int       `json:"down_payment"`
-	MonthlyFee  int       `json:"monthly_fee"`
-	CostPerHour int       `json:"cost_per_hour"`
-	TotalFee    int       `json:"total_fee"`
+	DownPayment Money     `json:"down_payment"`
+	MonthlyFee  Money     `json:"monthly_fee"`
+	CostPerHour Money     `json:"cost_per_hour"`
+	TotalFee    Money     `json:"total_fee"`
 	Uptime      int       `json:"uptime"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	ClientID    uuid.UUID `json:"client_id"`
+	// TaxCarry is the tax below one currency unit owed so far, see
+	// TaxRule.Calculate.
+	TaxCarry  int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ClientID  uuid.UUID `json:"client_id"`
 }
 
-func (b *Billing) CalculateCostPerHour() int {
-	cost := (b.CPU * CPUPrice) + (b.RAM / 1024 * RAMPrice) + (b.Storage * StoragePrice)
-	return cost
+func (b *Billing) CalculateCostPerHour(prices PriceList) (Money, error) {
+	cpu, err := NewMoney(prices.CPU, prices.Currency).Mul(int64(b.CPU))
+	if err != nil {
+		return Money{}, err
+	}
+	ram, err := NewMoney(prices.RAM, prices.Currency).Mul(int64(b.RAM / 1024))
+	if err != nil {
+		return Money{}, err
+	}
+	storage, err := NewMoney(prices.Storage, prices.Currency).Mul(int64(b.Storage))
+	if err != nil {
+		return Money{}, err
+	}
+	return Sum(cpu, ram, storage)
 }
 
-func (b *Billing) CalculateMonthlyFee() int {
-	hourlyCost := b.CalculateCostPerHour()
-	monthlyCost := hourlyCost * 24 * 30
-	return monthlyCost
+func (b *Billing) CalculateMonthlyFee(prices PriceList) (Money, error) {
+	hourlyCost, err := b.CalculateCostPerHour(prices)
+	if err != nil {
+		return Money{}, err
+	}
+	return hourlyCost.Mul(24 * 30)
 }
-
@@ -0,0 +1,16 @@
+package model
+
+import "github.com/google/uuid"
+
+// ChargeResult is the outcome of charging one client in a batch.
+type ChargeResult struct {
+	ClientID     uuid.UUID
+	BillingID    uuid.UUID
+	Plan         string
+	Amount       Money
+	TaxAmount    Money
+	BalanceAfter Money
+	MonthlyFee   Money
+	ExchangeRate Rate
+	Suspended    bool
+}
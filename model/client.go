@@ -11,8 +11,10 @@ type Client struct {
 	Email     string    `json:"email"`
 	Suspended bool      `json:"suspended"`
 	Plan      string    `json:"plan"`
-	Balance   int       `json:"balance"`
+	Currency  Currency  `json:"currency"`
+	Balance   Money     `json:"balance"`
+	TaxID     string    `json:"tax_id"`
+	TaxExempt bool      `json:"tax_exempt"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
-
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+// rateDecimals is the precision exchange rates are kept in.
+const rateDecimals = 6
+
+// Rate is an exchange rate with six decimal places, held as millionths so it
+// never goes through floating point. It is the number of rupiah one major
+// unit of a currency is worth.
+type Rate int64
+
+// IdentityRate converts rupiah to rupiah.
+const IdentityRate Rate = 1_000_000
+
+// ExchangeRate is the rate of Currency from EffectiveFrom until the next rate
+// of the same currency takes over.
+type ExchangeRate struct {
+	RateID        uuid.UUID `json:"rate_id"`
+	Currency      Currency  `json:"currency"`
+	Rate          Rate      `json:"rate"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ParseRate reads a positive decimal such as 16250.5 with at most six
+// decimal places.
+func ParseRate(s string) (Rate, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" || len(frac) > rateDecimals {
+		return 0, fmt.Errorf("rate %q must be a decimal with at most %d decimal places", s, rateDecimals)
+	}
+	digits := whole + frac + strings.Repeat("0", rateDecimals-len(frac))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("rate %q must be a decimal with at most %d decimal places", s, rateDecimals)
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("rate %q: %w", s, utils.ErrOverflow)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("rate %q must be greater than 0", s)
+	}
+	return Rate(n), nil
+}
+
+func (r Rate) String() string {
+	s := fmt.Sprintf("%d.%06d", r/IdentityRate, r%IdentityRate)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// MarshalJSON writes the rate as a string so clients don't lose precision.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts the rate as a string or a plain JSON number.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	rate, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// Convert returns m in rupiah at rate r, rounded half away from zero.
+func (r Rate) Convert(m Money) (Money, error) {
+	if m.currency() == IDR {
+		return m, nil
+	}
+	num := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(int64(r)))
+	den := new(big.Int).Mul(big.NewInt(int64(IdentityRate)),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(m.currency().MinorUnits())), nil))
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return Money{}, fmt.Errorf("%s at %s: %w", m, r, utils.ErrOverflow)
+	}
+	return Rupiah(q.Int64()), nil
+}
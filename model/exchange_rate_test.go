@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{"16250.5", 16250500000, false},
+		{"1", IdentityRate, false},
+		{"0.000001", 1, false},
+		{"0", 0, true},
+		{"0.000000", 0, true},
+		{"-1", 0, true},
+		{"1.0000001", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
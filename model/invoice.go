@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	InvoiceOpen = "open"
+	InvoicePaid = "paid"
+	InvoiceVoid = "void"
+
+	ComputeLine     = "compute"
+	DownPaymentLine = "down_payment"
+	AdjustmentLine  = "adjustment"
+)
+
+type Invoice struct {
+	InvoiceID     uuid.UUID     `json:"invoice_id"`
+	InvoiceNumber string        `json:"invoice_number"`
+	ClientID      uuid.UUID     `json:"client_id"`
+	Email         string        `json:"email"`
+	TaxID         string        `json:"tax_id"`
+	BillingID     uuid.UUID     `json:"billing_id"`
+	PeriodStart   time.Time     `json:"period_start"`
+	PeriodEnd     time.Time     `json:"period_end"`
+	Status        string        `json:"status"`
+	Subtotal      Money         `json:"subtotal"`
+	TaxTotal      Money         `json:"tax_total"`
+	Total         Money         `json:"total"`
+	Lines         []InvoiceLine `json:"lines"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+type InvoiceLine struct {
+	LineID      uuid.UUID `json:"line_id"`
+	InvoiceID   uuid.UUID `json:"invoice_id"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Quantity    int       `json:"quantity"`
+	UnitPrice   Money     `json:"unit_price"`
+	Amount      Money     `json:"amount"`
+	TaxAmount   Money     `json:"tax_amount"`
+}
+
+// InvoiceCandidate is a billing that has activity in a period but no invoice for it yet.
+type InvoiceCandidate struct {
+	BillingID     uuid.UUID
+	ClientID      uuid.UUID
+	Plan          string
+	TaxID         string
+	Currency      Currency
+	DownPayment   Money
+	ClientCreated time.Time
+}
+
+// TransactionSummary aggregates the transactions of one type within a period.
+type TransactionSummary struct {
+	Type      string
+	Count     int
+	Amount    Money
+	TaxAmount Money
+}
+
+// BillingLocation is the time zone invoice months are cut in, so the month
+// a charge falls in does not depend on the zone of the host or of t.
+var BillingLocation = time.UTC
+
+// MonthStart returns midnight of the first day of t's month in
+// BillingLocation.
+func MonthStart(t time.Time) time.Time {
+	t = t.In(BillingLocation)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, BillingLocation)
+}
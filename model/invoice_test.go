@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthStart(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	tests := []struct {
+		at   time.Time
+		want time.Time
+	}{
+		{time.Date(2025, time.March, 15, 12, 0, 0, 0, time.UTC), time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		// Already February in Jakarta, still January in the billing zone.
+		{time.Date(2025, time.February, 1, 3, 0, 0, 0, wib), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, time.February, 1, 7, 0, 0, 0, wib), time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got := MonthStart(tt.at)
+		if !got.Equal(tt.want) || got.Location() != BillingLocation {
+			t.Errorf("MonthStart(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	IDR Currency = "IDR"
+	SGD Currency = "SGD"
+	USD Currency = "USD"
+
+	// DefaultCurrency is used for amounts whose currency is not stored
+	// alongside them. It is also the base currency exchange rates convert to.
+	DefaultCurrency = IDR
+)
+
+// minorUnits is the number of decimal places the app bills in for each
+// supported currency.
+var minorUnits = map[Currency]int{
+	IDR: 0,
+	SGD: 2,
+	USD: 2,
+}
+
+func (c Currency) Valid() bool {
+	_, ok := minorUnits[c]
+	return ok
+}
+
+// MinorUnits returns how many decimal places amounts in c are kept in.
+func (c Currency) MinorUnits() int {
+	return minorUnits[c]
+}
+
+// Money is an amount in the smallest unit the app bills in for its currency
+// (whole rupiah for IDR, cents for SGD and USD). Arithmetic reports utils.ErrOverflow instead of
+// wrapping around and utils.ErrCurrency when currencies differ.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func NewMoney(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Rupiah returns amount in IDR.
+func Rupiah(amount int64) Money {
+	return Money{Amount: amount, Currency: IDR}
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %d", m.currency(), m.Amount)
+}
+
+// Decimal formats the amount in major units, e.g. 10.99 for 1099 USD cents.
+func (m Money) Decimal() string {
+	digits := m.currency().MinorUnits()
+	if digits == 0 {
+		return fmt.Sprintf("%d", m.Amount)
+	}
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign = "-"
+	}
+	whole, frac := amount/pow10(digits), amount%pow10(digits)
+	if frac < 0 {
+		whole, frac = -whole, -frac
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, digits, frac)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+func (m Money) currency() Currency {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func (m Money) check(o Money) error {
+	if m.currency() != o.currency() {
+		return fmt.Errorf("%s and %s: %w", m.currency(), o.currency(), utils.ErrCurrency)
+	}
+	return nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.check(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%s + %d: %w", m, o.Amount, utils.ErrOverflow)
+	}
+	return Money{Amount: sum, Currency: m.currency()}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	neg, err := o.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(neg)
+}
+
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("-(%s): %w", m, utils.ErrOverflow)
+	}
+	return Money{Amount: -m.Amount, Currency: m.currency()}, nil
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return Money{Currency: m.currency()}, nil
+	}
+	product := m.Amount * n
+	if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, fmt.Errorf("%s * %d: %w", m, n, utils.ErrOverflow)
+	}
+	return Money{Amount: product, Currency: m.currency()}, nil
+}
+
+// Div divides m into n equal parts, truncating toward zero. n must be positive.
+func (m Money) Div(n int64) Money {
+	if n <= 0 {
+		return Money{Currency: m.currency()}
+	}
+	return Money{Amount: m.Amount / n, Currency: m.currency()}
+}
+
+// MulBps returns m multiplied by bps basis points (1100 is 11%), rounded half
+// away from zero.
+func (m Money) MulBps(bps int64) (Money, error) {
+	scaled, err := m.Mul(bps)
+	if err != nil {
+		return Money{}, err
+	}
+	half := Money{Amount: 5000, Currency: m.currency()}
+	if scaled.Amount < 0 {
+		half.Amount = -half.Amount
+	}
+	if scaled, err = scaled.Add(half); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: scaled.Amount / 10000, Currency: m.currency()}, nil
+}
+
+// Less reports whether m is smaller than o. Amounts in different currencies
+// can't be compared and report utils.ErrCurrency.
+func (m Money) Less(o Money) (bool, error) {
+	if err := m.check(o); err != nil {
+		return false, err
+	}
+	return m.Amount < o.Amount, nil
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Sum adds amounts, which must all share a currency.
+func Sum(first Money, rest ...Money) (Money, error) {
+	total := first
+	for _, m := range rest {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Scan reads a BIGINT column. The currency is kept if already set, and
+// otherwise becomes DefaultCurrency.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		m.Amount = v
+	case int32:
+		m.Amount = int64(v)
+	case nil:
+		return fmt.Errorf("cannot scan NULL into Money")
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	m.Currency = m.currency()
+	return nil
+}
+
+// Value stores the amount only; the currency lives in its own column.
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+func TestMoneyLess(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    bool
+		wantErr error
+	}{
+		{"smaller", Rupiah(100), Rupiah(200), true, nil},
+		{"equal", Rupiah(200), Rupiah(200), false, nil},
+		{"larger", NewMoney(300, USD), NewMoney(200, USD), false, nil},
+		{"unset currency is rupiah", Money{Amount: 100}, Rupiah(200), true, nil},
+		{"different currencies", NewMoney(100, USD), Rupiah(200), false, utils.ErrCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Less(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Less() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("%s.Less(%s) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -9,24 +9,48 @@ const (
 	PremiumBilling = "premium"
 )
 
-// Price per hour
+// Resources of each plan
 var (
 	Basic = Billing{
-		CPU:         1,
-		RAM:         1024,
-		Storage:     8,
-		DownPayment: 15000,
+		CPU:     1,
+		RAM:     1024,
+		Storage: 8,
 	}
 	Normal = Billing{
-		CPU:         2,
-		RAM:         2048,
-		Storage:     16,
-		DownPayment: 25000,
+		CPU:     2,
+		RAM:     2048,
+		Storage: 16,
 	}
 	Premium = Billing{
-		CPU:         4,
-		RAM:         4096,
-		Storage:     16,
-		DownPayment: 40000,
+		CPU:     4,
+		RAM:     4096,
+		Storage: 16,
 	}
-)
\ No newline at end of file
+)
+
+// PriceList holds the hourly price of one CPU core, one GB of RAM and one GB
+// of storage, and the down payment of each plan, in minor units of Currency.
+type PriceList struct {
+	Currency    Currency
+	CPU         int64
+	RAM         int64
+	Storage     int64
+	DownPayment map[string]int64
+}
+
+// Prices are set per currency rather than converted from rupiah, so foreign
+// clients pay round amounts that don't move with the exchange rate.
+var Prices = map[Currency]PriceList{
+	IDR: {
+		Currency: IDR, CPU: CPUPrice, RAM: RAMPrice, Storage: StoragePrice,
+		DownPayment: map[string]int64{BasicBilling: 15000, NormalBilling: 25000, PremiumBilling: 40000},
+	},
+	SGD: {
+		Currency: SGD, CPU: 2, RAM: 2, Storage: 1,
+		DownPayment: map[string]int64{BasicBilling: 130, NormalBilling: 210, PremiumBilling: 330},
+	},
+	USD: {
+		Currency: USD, CPU: 1, RAM: 1, Storage: 1,
+		DownPayment: map[string]int64{BasicBilling: 100, NormalBilling: 160, PremiumBilling: 250},
+	},
+}
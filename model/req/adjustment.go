@@ -0,0 +1,59 @@
+package req
+
+import (
+	"strings"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+// MaxAdjustmentNoteLen bounds the note kept with an adjustment.
+const MaxAdjustmentNoteLen = 500
+
+type NewAdjustment struct {
+	Amount     int64          `json:"amount"`
+	Currency   model.Currency `json:"currency"`
+	ReasonCode string         `json:"reason_code"`
+	Note       string         `json:"note"`
+}
+
+// Validate checks the input. Amount is signed and in the client's currency;
+// Currency is optional and, when given, must match it.
+func (n *NewAdjustment) Validate() error {
+	var errs utils.ValidationErrors
+	if n.Amount == 0 {
+		errs.Add("amount", "must not be 0")
+	}
+	n.Currency = model.Currency(strings.ToUpper(strings.TrimSpace(string(n.Currency))))
+	if n.Currency != "" && !n.Currency.Valid() {
+		errs.Add("currency", "must be one of %s, %s, %s", model.IDR, model.SGD, model.USD)
+	}
+	n.ReasonCode = strings.ToLower(strings.TrimSpace(n.ReasonCode))
+	switch n.ReasonCode {
+	case model.AdjustmentOutageCredit, model.AdjustmentMischarge, model.AdjustmentGoodwill, model.AdjustmentOther:
+	default:
+		errs.Add("reason_code", "must be one of %s, %s, %s, %s", model.AdjustmentOutageCredit, model.AdjustmentMischarge,
+			model.AdjustmentGoodwill, model.AdjustmentOther)
+	}
+	n.Note = strings.TrimSpace(n.Note)
+	if n.ReasonCode == model.AdjustmentOther && n.Note == "" {
+		errs.Add("note", "is required when reason_code is %s", model.AdjustmentOther)
+	}
+	if len(n.Note) > MaxAdjustmentNoteLen {
+		errs.Add("note", "must be at most %d characters", MaxAdjustmentNoteLen)
+	}
+	return errs.Err()
+}
+
+type AdjustmentReversal struct {
+	Note string `json:"note"`
+}
+
+func (a *AdjustmentReversal) Validate() error {
+	var errs utils.ValidationErrors
+	a.Note = strings.TrimSpace(a.Note)
+	if a.Note == "" || len(a.Note) > MaxAdjustmentNoteLen {
+		errs.Add("note", "is required and must be at most %d characters", MaxAdjustmentNoteLen)
+	}
+	return errs.Err()
+}
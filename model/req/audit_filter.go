@@ -0,0 +1,113 @@
+package req
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+// AuditFilter holds the audit log query parameters. Events are listed
+// newest first.
+type AuditFilter struct {
+	ClientID  *uuid.UUID
+	Action    string
+	ActorType string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Cursor    *AuditCursor
+}
+
+// AuditCursor points at the last event of the previous page.
+type AuditCursor struct {
+	OccurredAt time.Time `json:"t"`
+	EventID    uuid.UUID `json:"id"`
+}
+
+func (c *AuditCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeAuditCursor(s string) (*AuditCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c AuditCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseAuditFilter reads client_id, action, actor (system|admin|client),
+// from and to (RFC3339, to is exclusive), limit and cursor from a query
+// string.
+func ParseAuditFilter(q url.Values) (*AuditFilter, error) {
+	var errs utils.ValidationErrors
+	filter := &AuditFilter{Limit: DefaultListLimit}
+
+	if v := q.Get("client_id"); v != "" {
+		clientID, err := uuid.Parse(v)
+		if err != nil {
+			errs.Add("client_id", "must be a UUID")
+		} else {
+			filter.ClientID = &clientID
+		}
+	}
+	filter.Action = q.Get("action")
+	if v := q.Get("actor"); v != "" {
+		switch v {
+		case utils.ActorSystem, utils.ActorAdmin, utils.ActorClient:
+			filter.ActorType = v
+		default:
+			errs.Add("actor", "must be one of %s, %s, %s", utils.ActorSystem, utils.ActorAdmin, utils.ActorClient)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs.Add("from", "must be an RFC3339 timestamp")
+		} else {
+			filter.From = &from
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs.Add("to", "must be an RFC3339 timestamp")
+		} else {
+			filter.To = &to
+		}
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		errs.Add("to", "must not be before from")
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxListLimit {
+			errs.Add("limit", "must be between 1 and %d", MaxListLimit)
+		} else {
+			filter.Limit = limit
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := decodeAuditCursor(v)
+		if err != nil {
+			errs.Add("cursor", "is malformed")
+		} else {
+			filter.Cursor = cursor
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
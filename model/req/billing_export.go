@@ -0,0 +1,56 @@
+package req
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+type BillingExport struct {
+	From   time.Time
+	To     time.Time
+	Format string
+}
+
+// ParseBillingExport accepts dates as RFC3339 or YYYY-MM-DD. The range is
+// half-open, so from=2025-01-01&to=2025-02-01 covers all of January.
+func ParseBillingExport(from, to, format string) (*BillingExport, error) {
+	var errs utils.ValidationErrors
+	export := &BillingExport{Format: strings.ToLower(format)}
+
+	var err error
+	if export.From, err = parseDate(from); err != nil {
+		errs.Add("from", "must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+	}
+	if export.To, err = parseDate(to); err != nil {
+		errs.Add("to", "must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+	}
+	if !export.From.IsZero() && !export.To.IsZero() && !export.To.After(export.From) {
+		errs.Add("to", "must be after from")
+	}
+	switch export.Format {
+	case "":
+		export.Format = ExportCSV
+	case ExportCSV, ExportNDJSON:
+	default:
+		errs.Add("format", "must be %s or %s", ExportCSV, ExportNDJSON)
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
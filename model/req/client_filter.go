@@ -0,0 +1,163 @@
+package req
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	SortCreatedAt = "created_at"
+	SortBalance   = "balance"
+	SortEmail     = "email"
+
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// ClientFilter holds the admin client listing query parameters.
+type ClientFilter struct {
+	Suspended    *bool
+	Plan         string
+	Currency     model.Currency
+	BalanceBelow *int64
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	SortBy       string
+	Desc         bool
+	Limit        int
+	Cursor       *ClientCursor
+}
+
+// ClientCursor points at the last row of the previous page. Value holds the
+// sort column of that row in its text form.
+type ClientCursor struct {
+	SortBy   string    `json:"s"`
+	Desc     bool      `json:"d"`
+	Value    string    `json:"v"`
+	ClientID uuid.UUID `json:"id"`
+}
+
+func (c *ClientCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeClientCursor(s string) (*ClientCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c ClientCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseClientFilter reads the listing parameters from a query string:
+// suspended, plan, currency, balance_below, created_from, created_to
+// (RFC3339), sort (created_at|balance|email, prefix with "-" for
+// descending), limit and cursor. Balances are only comparable within one
+// currency, so balance_below and sorting by balance require currency.
+func ParseClientFilter(q url.Values) (*ClientFilter, error) {
+	var errs utils.ValidationErrors
+	filter := &ClientFilter{SortBy: SortCreatedAt, Limit: DefaultListLimit}
+
+	if v := q.Get("suspended"); v != "" {
+		suspended, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.Add("suspended", "must be true or false")
+		} else {
+			filter.Suspended = &suspended
+		}
+	}
+	if v := strings.ToLower(q.Get("plan")); v != "" {
+		switch v {
+		case model.BasicBilling, model.NormalBilling, model.PremiumBilling:
+			filter.Plan = v
+		default:
+			errs.Add("plan", "must be one of %s, %s, %s", model.BasicBilling, model.NormalBilling, model.PremiumBilling)
+		}
+	}
+	if v := q.Get("currency"); v != "" {
+		filter.Currency = model.Currency(strings.ToUpper(v))
+		if !filter.Currency.Valid() {
+			errs.Add("currency", "must be one of %s, %s, %s", model.IDR, model.SGD, model.USD)
+		}
+	}
+	if v := q.Get("balance_below"); v != "" {
+		balance, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			errs.Add("balance_below", "must be an integer")
+		} else {
+			filter.BalanceBelow = &balance
+		}
+	}
+	if v := q.Get("created_from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs.Add("created_from", "must be an RFC3339 timestamp")
+		} else {
+			filter.CreatedFrom = &from
+		}
+	}
+	if v := q.Get("created_to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs.Add("created_to", "must be an RFC3339 timestamp")
+		} else {
+			filter.CreatedTo = &to
+		}
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedTo.Before(*filter.CreatedFrom) {
+		errs.Add("created_to", "must not be before created_from")
+	}
+	if v := q.Get("sort"); v != "" {
+		filter.Desc = strings.HasPrefix(v, "-")
+		filter.SortBy = strings.TrimPrefix(v, "-")
+		switch filter.SortBy {
+		case SortCreatedAt, SortBalance, SortEmail:
+		default:
+			errs.Add("sort", "must be one of %s, %s, %s", SortCreatedAt, SortBalance, SortEmail)
+		}
+	}
+	if filter.Currency == "" {
+		if filter.BalanceBelow != nil {
+			errs.Add("currency", "is required with balance_below")
+		}
+		if filter.SortBy == SortBalance {
+			errs.Add("currency", "is required when sorting by balance")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxListLimit {
+			errs.Add("limit", "must be between 1 and %d", MaxListLimit)
+		} else {
+			filter.Limit = limit
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := decodeClientCursor(v)
+		if err != nil {
+			errs.Add("cursor", "is malformed")
+		} else if cursor.SortBy != filter.SortBy || cursor.Desc != filter.Desc {
+			errs.Add("cursor", "was issued for a different sort order")
+		} else {
+			filter.Cursor = cursor
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
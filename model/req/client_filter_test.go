@@ -0,0 +1,45 @@
+package req
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+func TestParseClientFilterBalanceNeedsCurrency(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"balance_below=1000", true},
+		{"sort=-balance", true},
+		{"balance_below=1000&currency=usd", false},
+		{"sort=balance&currency=IDR", false},
+		{"currency=EUR", true},
+		{"sort=email", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ParseClientFilter(q)
+			if got := errors.Is(err, utils.ErrValidation); got != tt.wantErr {
+				t.Errorf("ParseClientFilter(%q) error = %v, want error %t", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+
+	q, _ := url.ParseQuery("balance_below=1000&currency=usd")
+	filter, err := ParseClientFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Currency != model.USD {
+		t.Errorf("Currency = %q, want %q", filter.Currency, model.USD)
+	}
+}
@@ -0,0 +1,33 @@
+package req
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+type NewExchangeRate struct {
+	Currency      model.Currency `json:"currency"`
+	Rate          model.Rate     `json:"rate"`
+	EffectiveFrom time.Time      `json:"effective_from"`
+}
+
+func (n *NewExchangeRate) Validate() error {
+	var errs utils.ValidationErrors
+	n.Currency = model.Currency(strings.ToUpper(strings.TrimSpace(string(n.Currency))))
+	switch {
+	case n.Currency == model.DefaultCurrency:
+		errs.Add("currency", "%s is the base currency and has no exchange rate", model.DefaultCurrency)
+	case !n.Currency.Valid():
+		errs.Add("currency", "must be one of %s, %s", model.SGD, model.USD)
+	}
+	if n.Rate <= 0 {
+		errs.Add("rate", "must be greater than 0")
+	}
+	if n.EffectiveFrom.IsZero() {
+		errs.Add("effective_from", "is required")
+	}
+	return errs.Err()
+}
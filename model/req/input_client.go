@@ -1,7 +1,62 @@
 package req
 
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+// EmailMaxLength matches the clients.email VARCHAR(50) column.
+const EmailMaxLength = 50
+
 type NewClient struct {
-	Email   string `json:"email"`
-	Balance int    `json:"balance"`
-	Plan    string `json:"plan"`
-}
\ No newline at end of file
+	Email    string         `json:"email"`
+	Balance  int64          `json:"balance"`
+	Currency model.Currency `json:"currency"`
+	Plan     string         `json:"plan"`
+	TaxID    string         `json:"tax_id"`
+}
+
+// Validate normalizes the input and reports every invalid field at once.
+func (n *NewClient) Validate() error {
+	var errs utils.ValidationErrors
+
+	n.Email = strings.ToLower(strings.TrimSpace(n.Email))
+	switch {
+	case n.Email == "":
+		errs.Add("email", "is required")
+	case len(n.Email) > EmailMaxLength:
+		errs.Add("email", "must be at most %d characters", EmailMaxLength)
+	default:
+		addr, err := mail.ParseAddress(n.Email)
+		if err != nil || addr.Address != n.Email {
+			errs.Add("email", "is not a valid email address")
+		}
+	}
+
+	if n.Balance <= 0 {
+		errs.Add("balance", "must be a positive integer")
+	}
+
+	n.Currency = model.Currency(strings.ToUpper(strings.TrimSpace(string(n.Currency))))
+	if n.Currency == "" {
+		n.Currency = model.DefaultCurrency
+	} else if !n.Currency.Valid() {
+		errs.Add("currency", "must be one of %s, %s, %s", model.IDR, model.SGD, model.USD)
+	}
+
+	n.Plan = strings.ToLower(strings.TrimSpace(n.Plan))
+	switch n.Plan {
+	case model.BasicBilling, model.NormalBilling, model.PremiumBilling:
+	case "":
+		errs.Add("plan", "is required")
+	default:
+		errs.Add("plan", "must be one of %s, %s, %s", model.BasicBilling, model.NormalBilling, model.PremiumBilling)
+	}
+
+	n.TaxID = normalizeNPWP(n.TaxID, "tax_id", &errs)
+
+	return errs.Err()
+}
@@ -0,0 +1,24 @@
+package req
+
+import (
+	"strings"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+type InvoiceStatus struct {
+	Status string `json:"status"`
+}
+
+// Validate only accepts the states an open invoice can move to.
+func (s *InvoiceStatus) Validate() error {
+	var errs utils.ValidationErrors
+	s.Status = strings.ToLower(strings.TrimSpace(s.Status))
+	switch s.Status {
+	case model.InvoicePaid, model.InvoiceVoid:
+	default:
+		errs.Add("status", "must be %s or %s", model.InvoicePaid, model.InvoiceVoid)
+	}
+	return errs.Err()
+}
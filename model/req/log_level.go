@@ -0,0 +1,21 @@
+package req
+
+import (
+	"strings"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap/zapcore"
+)
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+func (l *LogLevel) Validate() error {
+	var errs utils.ValidationErrors
+	l.Level = strings.ToLower(strings.TrimSpace(l.Level))
+	if _, err := zapcore.ParseLevel(l.Level); err != nil || l.Level == "" {
+		errs.Add("level", "must be debug, info, warn or error")
+	}
+	return errs.Err()
+}
@@ -0,0 +1,66 @@
+package req
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+const MaxTaxRateBps = 10000
+
+type NewTaxRule struct {
+	Name          string     `json:"name"`
+	RateBps       int        `json:"rate_bps"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+}
+
+func (n *NewTaxRule) Validate() error {
+	var errs utils.ValidationErrors
+	n.Name = strings.ToUpper(strings.TrimSpace(n.Name))
+	if n.Name == "" || len(n.Name) > 20 {
+		errs.Add("name", "is required and must be at most 20 characters")
+	}
+	if n.RateBps < 0 || n.RateBps > MaxTaxRateBps {
+		errs.Add("rate_bps", "must be between 0 and %d", MaxTaxRateBps)
+	}
+	if n.EffectiveFrom.IsZero() {
+		errs.Add("effective_from", "is required")
+	}
+	if n.EffectiveTo != nil && !n.EffectiveTo.After(n.EffectiveFrom) {
+		errs.Add("effective_to", "must be after effective_from")
+	}
+	return errs.Err()
+}
+
+type ClientTax struct {
+	TaxID     string `json:"tax_id"`
+	TaxExempt bool   `json:"tax_exempt"`
+}
+
+func (c *ClientTax) Validate() error {
+	var errs utils.ValidationErrors
+	c.TaxID = normalizeNPWP(c.TaxID, "tax_id", &errs)
+	return errs.Err()
+}
+
+// normalizeNPWP strips the usual NPWP punctuation (01.234.567.8-901.000) and
+// checks for the 15 digit legacy or 16 digit NIK-based format. Empty is allowed.
+func normalizeNPWP(npwp, field string, errs *utils.ValidationErrors) string {
+	npwp = strings.NewReplacer(".", "", "-", "", " ", "").Replace(npwp)
+	if npwp == "" {
+		return ""
+	}
+	if len(npwp) != 15 && len(npwp) != 16 {
+		errs.Add(field, "must be a 15 or 16 digit NPWP")
+		return npwp
+	}
+	for _, r := range npwp {
+		if r < '0' || r > '9' {
+			errs.Add(field, "must only contain digits")
+			break
+		}
+	}
+	return npwp
+}
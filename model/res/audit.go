@@ -0,0 +1,8 @@
+package res
+
+import "github.com/bagasadiii/maxcloud_vps/model"
+
+type AuditList struct {
+	Events     []model.AuditEvent `json:"events"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,25 @@
+package res
+
+import (
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+
+	"github.com/google/uuid"
+)
+
+type BillingHistory struct {
+	TransactionID uuid.UUID   `json:"transaction_id"`
+	ClientID      uuid.UUID   `json:"client_id"`
+	Email         string      `json:"email"`
+	BillingID     uuid.UUID   `json:"billing_id"`
+	Type          string      `json:"type"`
+	PeriodStart   time.Time   `json:"period_start"`
+	PeriodEnd     time.Time   `json:"period_end"`
+	Amount        model.Money `json:"amount"`
+	TaxAmount     model.Money `json:"tax_amount"`
+	BalanceAfter  model.Money `json:"balance_after"`
+	ExchangeRate  model.Rate  `json:"exchange_rate"`
+	TotalIDR      model.Money `json:"total_idr"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
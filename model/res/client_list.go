@@ -0,0 +1,25 @@
+package res
+
+import (
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+
+	"github.com/google/uuid"
+)
+
+type ClientSummary struct {
+	ClientID  uuid.UUID   `json:"client_id"`
+	Email     string      `json:"email"`
+	Plan      string      `json:"plan"`
+	Suspended bool        `json:"suspended"`
+	Balance   model.Money `json:"balance"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type ClientList struct {
+	Clients    []ClientSummary `json:"clients"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -3,6 +3,8 @@ package res
 import (
 	"time"
 
+	"github.com/bagasadiii/maxcloud_vps/model"
+
 	"github.com/google/uuid"
 )
 
@@ -11,16 +13,20 @@ type ClientInfo struct {
 	Email          string
 	Suspended      bool
 	Plan           string
-	Balance        int
+	Currency       model.Currency
+	Balance        model.Money
+	TaxID          string
+	TaxExempt      bool
 	ClientCreated  time.Time
 	ClientUpdated  time.Time
 	BillingID      uuid.UUID
 	CPU            int
 	RAM            int
 	Storage        int
-	MonthlyFee     int
-	CostPerHour    int
-	TotalFee       int
+	DownPayment    model.Money
+	MonthlyFee     model.Money
+	CostPerHour    model.Money
+	TotalFee       model.Money
 	Uptime         int
 	BillingCreated time.Time
 	BillingUpdated time.Time
@@ -0,0 +1,17 @@
+package res
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Detail any    `json:"detail,omitempty"`
+}
+
+type Health struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
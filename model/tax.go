@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PPN is the Indonesian value added tax (Pajak Pertambahan Nilai).
+	PPN = "PPN"
+)
+
+// TaxRule is a tax rate valid from EffectiveFrom until EffectiveTo. A nil
+// EffectiveTo means the rule has no end date. When rules overlap, the one
+// with the latest EffectiveFrom wins.
+type TaxRule struct {
+	RuleID        uuid.UUID  `json:"rule_id"`
+	Name          string     `json:"name"`
+	RateBps       int        `json:"rate_bps"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TaxCarryScale is how many parts of the smallest currency unit tax is
+// worked out in: amount times RateBps is in ten-thousandths of a unit.
+const TaxCarryScale = 10000
+
+// Calculate returns the tax owed on a tax-exclusive amount in whole units,
+// and what is left below one unit. Passing that remainder back as carry on
+// the next charge makes the tax on many small charges add up to the tax on
+// their total, instead of each charge rounding on its own. RateBps is in
+// basis points, 1100 is 11%.
+func (t *TaxRule) Calculate(amount Money, carry int64) (Money, int64, error) {
+	if t == nil {
+		return Money{Currency: amount.Currency}, carry, nil
+	}
+	scaled, err := amount.Mul(int64(t.RateBps))
+	if err != nil {
+		return Money{}, 0, err
+	}
+	if scaled, err = scaled.Add(Money{Amount: carry, Currency: scaled.Currency}); err != nil {
+		return Money{}, 0, err
+	}
+	return scaled.Div(TaxCarryScale), scaled.Amount % TaxCarryScale, nil
+}
@@ -0,0 +1,42 @@
+package model
+
+import "testing"
+
+// Hourly charges of a few cents must add up to the full rate over a month,
+// rather than each rounding its tax to 0 or 1 cent.
+func TestTaxRuleCalculateCarry(t *testing.T) {
+	rule := &TaxRule{Name: PPN, RateBps: 1100}
+	for _, cost := range []Money{NewMoney(3, USD), NewMoney(5, SGD), Rupiah(1234)} {
+		const hours = 720
+		total := Money{Currency: cost.Currency}
+		var carry int64
+		for i := 0; i < hours; i++ {
+			tax, next, err := rule.Calculate(cost, carry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next < 0 || next >= TaxCarryScale {
+				t.Fatalf("carry %d out of range", next)
+			}
+			if total, err = total.Add(tax); err != nil {
+				t.Fatal(err)
+			}
+			carry = next
+		}
+		exact := cost.Amount * hours * int64(rule.RateBps)
+		if want := exact / TaxCarryScale; total.Amount != want {
+			t.Errorf("tax on %d hours of %s = %d, want %d", hours, cost, total.Amount, want)
+		}
+		if carry != exact%TaxCarryScale {
+			t.Errorf("carry = %d, want %d", carry, exact%TaxCarryScale)
+		}
+	}
+}
+
+func TestTaxRuleCalculateNil(t *testing.T) {
+	var rule *TaxRule
+	tax, carry, err := rule.Calculate(Rupiah(1000), 42)
+	if err != nil || !tax.IsZero() || carry != 42 {
+		t.Errorf("nil rule = %s, %d, %v, want no tax and the carry unchanged", tax, carry, err)
+	}
+}
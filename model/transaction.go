@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HourlyChargeTransaction = "hourly_charge"
+	AdjustmentTransaction   = "adjustment"
+)
+
+// Transaction is one entry of a client's billing history. Amount is what the
+// client is charged, so an adjustment crediting the balance has a negative
+// Amount.
+type Transaction struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	ClientID      uuid.UUID `json:"client_id"`
+	BillingID     uuid.UUID `json:"billing_id"`
+	Type          string    `json:"type"`
+	Amount        Money     `json:"amount"`
+	TaxAmount     Money     `json:"tax_amount"`
+	BalanceAfter  Money     `json:"balance_after"`
+	ExchangeRate  Rate      `json:"exchange_rate"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -10,11 +10,14 @@ type UpdateClient struct {
 	ClientID    uuid.UUID
 	BillingID   uuid.UUID
 	Suspended   bool
-	Balance     int
-	MonthlyFee  int
-	CostPerHour int
-	TotalFee    int
+	TaxExempt   bool
+	Plan        string
+	Currency    Currency
+	Balance     Money
+	MonthlyFee  Money
+	CostPerHour Money
+	TotalFee    Money
 	Uptime      int
+	TaxCarry    int64
 	UpdatedAt   time.Time
 }
-
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type AdjustmentRepoImpl interface {
+	LockAdjustmentClient(ctx context.Context, clientID uuid.UUID) (*model.UpdateClient, error)
+	GetAdjustment(ctx context.Context, clientID, transactionID uuid.UUID) (*model.Adjustment, error)
+	ListAdjustments(ctx context.Context, clientID uuid.UUID) ([]model.Adjustment, error)
+	InsertAdjustment(ctx context.Context, adjustment *model.Adjustment) error
+}
+
+type AdjustmentRepo struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewAdjustmentRepo(db *pgxpool.Pool, logger *zap.Logger) *AdjustmentRepo {
+	return &AdjustmentRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// LockAdjustmentClient returns the client's billing, currency and balance,
+// and locks the client until the transaction in ctx ends so adjustments of
+// one client are made one at a time. Suspended clients can be adjusted too.
+func (ar *AdjustmentRepo) LockAdjustmentClient(ctx context.Context, clientID uuid.UUID) (*model.UpdateClient, error) {
+	var client model.UpdateClient
+	err := conn(ctx, ar.db).QueryRow(ctx, `
+	SELECT c.client_id, b.billing_id, c.suspended, c.currency, c.balance
+	FROM clients c
+	JOIN billings b ON b.client_id = c.client_id
+	WHERE c.client_id = $1
+	LIMIT 1
+	FOR UPDATE OF c
+	`, clientID).Scan(&client.ClientID, &client.BillingID, &client.Suspended, &client.Currency, &client.Balance)
+	if err == pgx.ErrNoRows {
+		info := "client id not found"
+		utils.Log(ctx, ar.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("client_id", clientID.String()))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed to get client"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	inCurrency(client.Currency, &client.Balance)
+	return &client, nil
+}
+
+const adjustmentColumns = `
+	a.transaction_id, a.client_id, a.currency, a.amount, t.balance_after, a.reason_code, a.note, a.reverses, r.transaction_id,
+	a.actor_type, a.actor_id, a.created_at
+	FROM adjustments a
+	JOIN transactions t ON t.transaction_id = a.transaction_id
+	LEFT JOIN adjustments r ON r.reverses = a.transaction_id`
+
+func scanAdjustment(row pgx.Row, adjustment *model.Adjustment) error {
+	var currency model.Currency
+	err := row.Scan(&adjustment.TransactionID, &adjustment.ClientID, &currency, &adjustment.Amount, &adjustment.BalanceAfter,
+		&adjustment.ReasonCode, &adjustment.Note, &adjustment.Reverses, &adjustment.ReversedBy,
+		&adjustment.CreatedBy.Type, &adjustment.CreatedBy.ID, &adjustment.CreatedAt)
+	inCurrency(currency, &adjustment.Amount, &adjustment.BalanceAfter)
+	return err
+}
+
+func (ar *AdjustmentRepo) GetAdjustment(ctx context.Context, clientID, transactionID uuid.UUID) (*model.Adjustment, error) {
+	var adjustment model.Adjustment
+	err := scanAdjustment(conn(ctx, ar.db).QueryRow(ctx, `SELECT`+adjustmentColumns+`
+	WHERE a.client_id = $1 AND a.transaction_id = $2
+	`, clientID, transactionID), &adjustment)
+	if err == pgx.ErrNoRows {
+		info := "adjustment not found"
+		utils.Log(ctx, ar.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("transaction_id", transactionID.String()))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed to get adjustment"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return &adjustment, nil
+}
+
+func (ar *AdjustmentRepo) ListAdjustments(ctx context.Context, clientID uuid.UUID) ([]model.Adjustment, error) {
+	rows, err := conn(ctx, ar.db).Query(ctx, `SELECT`+adjustmentColumns+`
+	WHERE a.client_id = $1
+	ORDER BY a.created_at, a.transaction_id
+	`, clientID)
+	if err != nil {
+		info := "failed to list adjustments"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	adjustments := []model.Adjustment{}
+	for rows.Next() {
+		var adjustment model.Adjustment
+		if err := scanAdjustment(rows, &adjustment); err != nil {
+			info := "failed while scanning adjustments"
+			utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading adjustments"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return adjustments, nil
+}
+
+// InsertAdjustment stores the details of an adjustment whose transaction is
+// already recorded. A second reversal of the same adjustment is rejected
+// with utils.ErrExists.
+func (ar *AdjustmentRepo) InsertAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	tag, err := conn(ctx, ar.db).Exec(ctx, `
+	INSERT INTO adjustments
+	(transaction_id, client_id, currency, amount, reason_code, note, reverses, actor_type, actor_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (reverses) DO NOTHING
+	`, adjustment.TransactionID, adjustment.ClientID, string(adjustment.Amount.Currency), adjustment.Amount,
+		adjustment.ReasonCode, adjustment.Note, adjustment.Reverses, adjustment.CreatedBy.Type, adjustment.CreatedBy.ID,
+		adjustment.CreatedAt)
+	if err != nil {
+		info := "failed to record adjustment"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info),
+			zap.String("client_id", adjustment.ClientID.String()), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	if tag.RowsAffected() == 0 {
+		info := "adjustment is already reversed"
+		utils.Log(ctx, ar.logger).Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("reverses", adjustment.Reverses.String()))
+		return fmt.Errorf("%s: %w", info, utils.ErrExists)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type AuditRepoImpl interface {
+	ListAuditEvents(ctx context.Context, filter *req.AuditFilter) (*res.AuditList, error)
+}
+
+type AuditRepo struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewAuditRepo(db *pgxpool.Pool, logger *zap.Logger) *AuditRepo {
+	return &AuditRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// recordAudit writes event with q, which should be the transaction making
+// the change, so the event exists exactly when the change does. The actor,
+// request ID and, unless event has one, the reason come from ctx.
+func recordAudit(ctx context.Context, q DBTX, logger *zap.Logger, event *model.AuditEvent) error {
+	event.EventID = uuid.New()
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	event.Actor = utils.ActorFrom(ctx)
+	event.RequestID = utils.RequestID(ctx)
+	if event.Reason == "" {
+		event.Reason = utils.AuditReason(ctx)
+	}
+	before, err := auditJSON(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditJSON(event.After)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+	INSERT INTO audit_events
+	(event_id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, client_id, before, after, reason, request_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, event.EventID, event.OccurredAt, event.Actor.Type, event.Actor.ID, event.Action, event.EntityType, event.EntityID,
+		event.ClientID, before, after, event.Reason, event.RequestID)
+	if err != nil {
+		info := "failed to record audit event"
+		utils.Log(ctx, logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.String("action", event.Action), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return nil
+}
+
+// auditJSON encodes a before or after value, nil stays NULL.
+func auditJSON(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit value: %v: %w", err, utils.ErrInternal)
+	}
+	return b, nil
+}
+
+func (ar *AuditRepo) ListAuditEvents(ctx context.Context, filter *req.AuditFilter) (*res.AuditList, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if filter.ClientID != nil {
+		where = append(where, "client_id = "+arg(*filter.ClientID))
+	}
+	if filter.Action != "" {
+		where = append(where, "action = "+arg(filter.Action))
+	}
+	if filter.ActorType != "" {
+		where = append(where, "actor_type = "+arg(filter.ActorType))
+	}
+	if filter.From != nil {
+		where = append(where, "occurred_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		where = append(where, "occurred_at < "+arg(*filter.To))
+	}
+	if filter.Cursor != nil {
+		where = append(where, fmt.Sprintf("(occurred_at, event_id) < (%s, %s)", arg(filter.Cursor.OccurredAt), arg(filter.Cursor.EventID)))
+	}
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := conn(ctx, ar.db).Query(ctx, fmt.Sprintf(`
+	SELECT event_id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, client_id,
+	  before, after, reason, request_id
+	FROM audit_events
+	%s
+	ORDER BY occurred_at DESC, event_id DESC
+	LIMIT %s
+	`, whereSQL, arg(filter.Limit+1)), args...)
+	if err != nil {
+		info := "failed to list audit events"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	list := res.AuditList{Events: []model.AuditEvent{}}
+	for rows.Next() {
+		var event model.AuditEvent
+		var before, after []byte
+		err := rows.Scan(&event.EventID, &event.OccurredAt, &event.Actor.Type, &event.Actor.ID, &event.Action,
+			&event.EntityType, &event.EntityID, &event.ClientID, &before, &after, &event.Reason, &event.RequestID)
+		if err != nil {
+			info := "failed while scanning audit events"
+			utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		if before != nil {
+			event.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			event.After = json.RawMessage(after)
+		}
+		list.Events = append(list.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading audit events"
+		utils.Log(ctx, ar.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+
+	if len(list.Events) > filter.Limit {
+		list.Events = list.Events[:filter.Limit]
+		last := list.Events[len(list.Events)-1]
+		cursor := req.AuditCursor{OccurredAt: last.OccurredAt, EventID: last.EventID}
+		list.NextCursor = cursor.Encode()
+	}
+	return &list, nil
+}
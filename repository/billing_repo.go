@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type BillingRepoImpl interface {
+	StreamBillingHistory(ctx context.Context, from, to time.Time, fn func(*res.BillingHistory) error) error
+}
+
+type BillingRepo struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewBillingRepo(db *pgxpool.Pool, logger *zap.Logger) *BillingRepo {
+	return &BillingRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// StreamBillingHistory calls fn for every transaction created in [from, to).
+// Rows are read one at a time from the connection, so the whole range is
+// never held in memory.
+func (br *BillingRepo) StreamBillingHistory(ctx context.Context, from, to time.Time, fn func(*res.BillingHistory) error) error {
+	rows, err := conn(ctx, br.db).Query(ctx, `
+	SELECT t.transaction_id, t.client_id, c.email, t.billing_id, t.type,
+	  t.period_start, t.period_end, t.currency, t.amount, t.tax_amount, t.balance_after, t.exchange_rate_micros, t.created_at
+	FROM transactions t
+	JOIN clients c ON c.client_id = t.client_id
+	WHERE t.created_at >= $1 AND t.created_at < $2
+	ORDER BY t.created_at, t.transaction_id
+	`, from, to)
+	if err != nil {
+		info := "failed to query billing history"
+		utils.Log(ctx, br.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	var history res.BillingHistory
+	for rows.Next() {
+		var currency model.Currency
+		err := rows.Scan(&history.TransactionID, &history.ClientID, &history.Email, &history.BillingID,
+			&history.Type, &history.PeriodStart, &history.PeriodEnd, &currency, &history.Amount,
+			&history.TaxAmount, &history.BalanceAfter, &history.ExchangeRate, &history.CreatedAt)
+		if err != nil {
+			info := "failed while scanning billing history"
+			utils.Log(ctx, br.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		inCurrency(currency, &history.Amount, &history.TaxAmount, &history.BalanceAfter)
+		if err := fn(&history); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading billing history"
+		utils.Log(ctx, br.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return nil
+}
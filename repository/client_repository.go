@@ -3,8 +3,12 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
 	"github.com/bagasadiii/maxcloud_vps/model/res"
 	"github.com/bagasadiii/maxcloud_vps/utils"
 	"github.com/google/uuid"
@@ -16,6 +20,8 @@ import (
 type ClientRepoImpl interface {
 	CreateClientRepo(ctx context.Context, client *model.Client, billing *model.Billing) error
 	GetClientInfoRepo(ctx context.Context, clientID uuid.UUID) (*res.ClientInfo, error)
+	ListClientsRepo(ctx context.Context, filter *req.ClientFilter) (*res.ClientList, error)
+	UpdateClientTaxRepo(ctx context.Context, clientID uuid.UUID, tax *req.ClientTax) error
 }
 
 type ClientRepo struct {
@@ -33,85 +39,254 @@ func NewClientRepo(db *pgxpool.Pool, logger *zap.Logger) *ClientRepo {
 func (cr *ClientRepo) CreateClientRepo(ctx context.Context, client *model.Client, billing *model.Billing) error {
 	// Register a client for using the VPS service
 	var exists bool
-	err := cr.db.QueryRow(ctx, `
+	err := conn(ctx, cr.db).QueryRow(ctx, `
     SELECT EXISTS (SELECT 1 FROM clients WHERE email = $1)
     `, client.Email).Scan(&exists)
 	if err != nil {
 		info := "error while checking client"
-		cr.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
 		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 	}
 	if exists {
 		info := "email exists"
-		cr.logger.Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("email", client.Email))
+		utils.Log(ctx, cr.logger).Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("email", client.Email))
 		return fmt.Errorf("%s: %w", info, utils.ErrExists)
 	}
-	tx, err := cr.db.Begin(ctx)
-	if err != nil {
-		info := "failed to begin transaction"
-		cr.logger.Error(utils.ErrDatabase.Error(), zap.Error(err))
-		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
-	}
-	defer func() {
+	err = withinTransaction(ctx, cr.db, cr.logger, func(ctx context.Context) error {
+		_, err := conn(ctx, cr.db).Exec(ctx, `
+		INSERT INTO clients
+		(client_id, email, plan, suspended, currency, balance, tax_id, tax_exempt, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10)
+		`, client.ClientID, client.Email, client.Plan, client.Suspended, string(client.Currency), client.Balance, client.TaxID, client.TaxExempt,
+			client.CreatedAt, client.UpdatedAt)
 		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
+			info := "failed to add client"
+			utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 		}
-	}()
-	_, err = tx.Exec(ctx, `
-    INSERT INTO clients
-    (client_id, email, suspended, balance, created_at, updated_at)
-    VALUES ($1, $2, $3, $4, $5, $6)
-		`, client.ClientID, client.Email, client.Suspended, client.Balance, client.CreatedAt, client.UpdatedAt)
-	if err != nil {
-		info := "failed to add client"
-		cr.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
-		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
-	}
 
-	_, err = tx.Exec(ctx, `
+		_, err = conn(ctx, cr.db).Exec(ctx, `
 		INSERT INTO billings
-		(billing_id, cpu, ram, storage, monthly_fee, cost_per_hour, total_fee, uptime, updated_at, client_id)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, billing.BillingID, billing.CPU, billing.RAM, billing.Storage, billing.MonthlyFee,
-		billing.CostPerHour, billing.TotalFee, billing.Uptime, billing.UpdatedAt, client.ClientID)
+		(billing_id, cpu, ram, storage, down_payment, monthly_fee, cost_per_hour, total_fee, uptime, created_at, updated_at, client_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`, billing.BillingID, billing.CPU, billing.RAM, billing.Storage, billing.DownPayment, billing.MonthlyFee,
+			billing.CostPerHour, billing.TotalFee, billing.Uptime, billing.CreatedAt, billing.UpdatedAt, client.ClientID)
+		if err != nil {
+			info := "failed to add billing"
+			utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		return recordAudit(ctx, conn(ctx, cr.db), cr.logger, &model.AuditEvent{
+			OccurredAt: client.CreatedAt,
+			Action:     model.AuditClientCreated,
+			EntityType: "client",
+			EntityID:   client.ClientID.String(),
+			ClientID:   &client.ClientID,
+			After: map[string]any{
+				"email":        client.Email,
+				"plan":         client.Plan,
+				"tax_id":       client.TaxID,
+				"tax_exempt":   client.TaxExempt,
+				"balance":      client.Balance,
+				"down_payment": billing.DownPayment,
+			},
+			Reason: model.ReasonRegistration,
+		})
+	})
 	if err != nil {
-		info := "failed to add billing"
-		cr.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
-		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		return err
 	}
-	cr.logger.Info("user and billing created", zap.String("email", client.Email),
+	utils.Log(ctx, cr.logger).Info("user and billing created", zap.String("email", client.Email),
 		zap.String("client_id", client.ClientID.String()))
 	return nil
 }
 
 func (cr *ClientRepo) GetClientInfoRepo(ctx context.Context, clientID uuid.UUID) (*res.ClientInfo, error) {
 	var clientInfo res.ClientInfo
-	err := cr.db.QueryRow(ctx, `
+	err := conn(ctx, cr.db).QueryRow(ctx, `
   SELECT
-	  c.client_id, c.email, c.suspended, c.balance, c.created_at, c.updated_at,
-	  b.billing_id, b.cpu, b.ram, b.storage, b.monthly_fee,
+	  c.client_id, c.email, COALESCE(c.plan, ''), c.suspended, c.currency, c.balance,
+	  COALESCE(c.tax_id, ''), c.tax_exempt, c.created_at, c.updated_at,
+	  b.billing_id, b.cpu, b.ram, b.storage, b.down_payment, b.monthly_fee,
 	  b.cost_per_hour, b.total_fee, b.uptime, b.created_at AS billing_created_at, b.updated_at AS billing_updated_at
 	FROM clients c
 	LEFT JOIN billings b ON c.client_id = b.client_id
 	WHERE c.client_id = $1
   `, clientID).Scan(
-		&clientInfo.ClientID, &clientInfo.Email, &clientInfo.Suspended, &clientInfo.Balance,
+		&clientInfo.ClientID, &clientInfo.Email, &clientInfo.Plan, &clientInfo.Suspended, &clientInfo.Currency, &clientInfo.Balance,
+		&clientInfo.TaxID, &clientInfo.TaxExempt,
 		&clientInfo.ClientCreated, &clientInfo.ClientUpdated,
-		&clientInfo.BillingID, &clientInfo.CPU, &clientInfo.RAM, &clientInfo.Storage,
+		&clientInfo.BillingID, &clientInfo.CPU, &clientInfo.RAM, &clientInfo.Storage, &clientInfo.DownPayment,
 		&clientInfo.MonthlyFee, &clientInfo.CostPerHour, &clientInfo.TotalFee, &clientInfo.Uptime,
 		&clientInfo.BillingCreated, &clientInfo.BillingUpdated,
 	)
 	if err == pgx.ErrNoRows {
 		info := "client id not found"
-		cr.logger.Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.Error(err))
+		utils.Log(ctx, cr.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.Error(err))
 
 		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
 	} else if err != nil {
 		info := "failed while scanning client data"
-		cr.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
 		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 	}
+	inCurrency(clientInfo.Currency, &clientInfo.Balance, &clientInfo.DownPayment, &clientInfo.MonthlyFee,
+		&clientInfo.CostPerHour, &clientInfo.TotalFee)
 	return &clientInfo, nil
 }
+
+func (cr *ClientRepo) ListClientsRepo(ctx context.Context, filter *req.ClientFilter) (*res.ClientList, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if filter.Suspended != nil {
+		where = append(where, "suspended = "+arg(*filter.Suspended))
+	}
+	if filter.Plan != "" {
+		where = append(where, "plan = "+arg(filter.Plan))
+	}
+	if filter.Currency != "" {
+		where = append(where, "currency = "+arg(string(filter.Currency)))
+	}
+	if filter.BalanceBelow != nil {
+		where = append(where, "balance < "+arg(*filter.BalanceBelow))
+	}
+	if filter.CreatedFrom != nil {
+		where = append(where, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		where = append(where, "created_at < "+arg(*filter.CreatedTo))
+	}
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var list res.ClientList
+	err := conn(ctx, cr.db).QueryRow(ctx, `SELECT COUNT(*) FROM clients `+whereSQL, args...).Scan(&list.Total)
+	if err != nil {
+		info := "failed to count clients"
+		utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+
+	// Keyset pagination on (sort column, client_id) so pages stay stable
+	// while clients are being registered.
+	column := filter.SortBy
+	op, order := ">", "ASC"
+	if filter.Desc {
+		op, order = "<", "DESC"
+	}
+	if filter.Cursor != nil {
+		value, err := cursorValue(column, filter.Cursor.Value)
+		if err != nil {
+			info := "invalid cursor value"
+			utils.Log(ctx, cr.logger).Warn(utils.ErrBadRequest.Error(), zap.String("warn", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrBadRequest)
+		}
+		where = append(where, fmt.Sprintf("(%s, client_id) %s (%s, %s)", column, op, arg(value), arg(filter.Cursor.ClientID)))
+		whereSQL = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := conn(ctx, cr.db).Query(ctx, fmt.Sprintf(`
+	SELECT client_id, email, COALESCE(plan, ''), suspended, currency, balance, created_at, updated_at
+	FROM clients
+	%s
+	ORDER BY %s %s, client_id %s
+	LIMIT %s
+	`, whereSQL, column, order, order, arg(filter.Limit+1)), args...)
+	if err != nil {
+		info := "failed to list clients"
+		utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	list.Clients = []res.ClientSummary{}
+	for rows.Next() {
+		var client res.ClientSummary
+		err := rows.Scan(&client.ClientID, &client.Email, &client.Plan, &client.Suspended,
+			&client.Balance.Currency, &client.Balance, &client.CreatedAt, &client.UpdatedAt)
+		if err != nil {
+			info := "failed while scanning client list"
+			utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		list.Clients = append(list.Clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading client list"
+		utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+
+	if len(list.Clients) > filter.Limit {
+		list.Clients = list.Clients[:filter.Limit]
+		last := list.Clients[len(list.Clients)-1]
+		cursor := req.ClientCursor{SortBy: filter.SortBy, Desc: filter.Desc, ClientID: last.ClientID}
+		switch column {
+		case req.SortBalance:
+			cursor.Value = strconv.FormatInt(last.Balance.Amount, 10)
+		case req.SortEmail:
+			cursor.Value = last.Email
+		default:
+			cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		list.NextCursor = cursor.Encode()
+	}
+	return &list, nil
+}
+
+func (cr *ClientRepo) UpdateClientTaxRepo(ctx context.Context, clientID uuid.UUID, tax *req.ClientTax) error {
+	err := withinTransaction(ctx, cr.db, cr.logger, func(ctx context.Context) error {
+		var before req.ClientTax
+		err := conn(ctx, cr.db).QueryRow(ctx, `
+		SELECT COALESCE(tax_id, ''), tax_exempt FROM clients WHERE client_id = $1 FOR UPDATE
+		`, clientID).Scan(&before.TaxID, &before.TaxExempt)
+		if err == pgx.ErrNoRows {
+			info := "client id not found"
+			utils.Log(ctx, cr.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("client_id", clientID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+		} else if err != nil {
+			info := "failed to read client tax settings"
+			utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		_, err = conn(ctx, cr.db).Exec(ctx, `
+		UPDATE clients SET tax_id = NULLIF($1, ''), tax_exempt = $2 WHERE client_id = $3
+		`, tax.TaxID, tax.TaxExempt, clientID)
+		if err != nil {
+			info := "failed to update client tax settings"
+			utils.Log(ctx, cr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		return recordAudit(ctx, conn(ctx, cr.db), cr.logger, &model.AuditEvent{
+			Action:     model.AuditClientTaxChanged,
+			EntityType: "client",
+			EntityID:   clientID.String(),
+			ClientID:   &clientID,
+			Before:     before,
+			After:      tax,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	utils.Log(ctx, cr.logger).Info("client tax settings updated", zap.String("client_id", clientID.String()),
+		zap.Bool("tax_exempt", tax.TaxExempt))
+	return nil
+}
+
+func cursorValue(column, value string) (any, error) {
+	switch column {
+	case req.SortBalance:
+		return strconv.ParseInt(value, 10, 64)
+	case req.SortEmail:
+		return value, nil
+	default:
+		return time.Parse(time.RFC3339Nano, value)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/repository/pgtest"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Every field written by CreateClientRepo comes back from GetClientInfoRepo
+// in its own place. Each one gets a distinct value so swapped columns show.
+func TestClientRepoRoundTrip(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+	repo := NewClientRepo(db, zap.NewNop())
+
+	client := &model.Client{
+		ClientID:  uuid.New(),
+		Email:     "roundtrip@example.com",
+		Plan:      model.NormalBilling,
+		TaxID:     "0123456789012345",
+		TaxExempt: true,
+		Currency:  model.USD,
+		Balance:   model.NewMoney(9999, model.USD),
+		CreatedAt: epoch,
+		UpdatedAt: epoch.Add(time.Hour),
+	}
+	billing := &model.Billing{
+		BillingID:   uuid.New(),
+		ClientID:    client.ClientID,
+		CPU:         model.Normal.CPU,
+		RAM:         model.Normal.RAM,
+		Storage:     model.Normal.Storage,
+		DownPayment: model.NewMoney(160, model.USD),
+		MonthlyFee:  model.NewMoney(14400, model.USD),
+		CostPerHour: model.NewMoney(20, model.USD),
+		TotalFee:    model.NewMoney(60, model.USD),
+		Uptime:      3,
+		CreatedAt:   epoch.Add(2 * time.Hour),
+		UpdatedAt:   epoch.Add(3 * time.Hour),
+	}
+	if err := repo.CreateClientRepo(ctx, client, billing); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetClientInfoRepo(ctx, client.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := res.ClientInfo{
+		ClientID:       client.ClientID,
+		Email:          client.Email,
+		Suspended:      false,
+		Plan:           model.NormalBilling,
+		Currency:       model.USD,
+		Balance:        client.Balance,
+		TaxID:          client.TaxID,
+		TaxExempt:      true,
+		ClientCreated:  client.CreatedAt,
+		ClientUpdated:  client.UpdatedAt,
+		BillingID:      billing.BillingID,
+		CPU:            billing.CPU,
+		RAM:            billing.RAM,
+		Storage:        billing.Storage,
+		DownPayment:    billing.DownPayment,
+		MonthlyFee:     billing.MonthlyFee,
+		CostPerHour:    billing.CostPerHour,
+		TotalFee:       billing.TotalFee,
+		Uptime:         billing.Uptime,
+		BillingCreated: billing.CreatedAt,
+		BillingUpdated: billing.UpdatedAt,
+	}
+	// Timestamps come back in the session time zone, so they are compared
+	// as instants and then left out of the comparison of the rest.
+	times := []struct {
+		name      string
+		got, want *time.Time
+	}{
+		{"client created", &got.ClientCreated, &want.ClientCreated},
+		{"client updated", &got.ClientUpdated, &want.ClientUpdated},
+		{"billing created", &got.BillingCreated, &want.BillingCreated},
+		{"billing updated", &got.BillingUpdated, &want.BillingUpdated},
+	}
+	for _, ts := range times {
+		if !ts.got.Equal(*ts.want) {
+			t.Errorf("%s = %s, want %s", ts.name, *ts.got, *ts.want)
+		}
+		*ts.got, *ts.want = time.Time{}, time.Time{}
+	}
+	if *got != want {
+		t.Errorf("GetClientInfoRepo =\n%+v\nwant\n%+v", *got, want)
+	}
+}
+
+// A client without a tax ID reads back with an empty one rather than NULL
+// failing the scan.
+func TestClientRepoRoundTripNoTaxID(t *testing.T) {
+	db := pgtest.Open(t)
+	client, _ := createTestClient(t, db, "notax@example.com", model.Rupiah(1500000))
+
+	got, err := NewClientRepo(db, zap.NewNop()).GetClientInfoRepo(context.Background(), client.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TaxID != "" || got.TaxExempt || got.Currency != model.IDR || got.Balance != model.Rupiah(1500000) {
+		t.Errorf("tax id %q, exempt %t, balance %s", got.TaxID, got.TaxExempt, got.Balance)
+	}
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type ExchangeRateRepoImpl interface {
+	GetExchangeRateAt(ctx context.Context, currency model.Currency, at time.Time) (*model.ExchangeRate, error)
+	ListExchangeRates(ctx context.Context) ([]model.ExchangeRate, error)
+	CreateExchangeRate(ctx context.Context, rate *model.ExchangeRate) error
+}
+
+type ExchangeRateRepo struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewExchangeRateRepo(db *pgxpool.Pool, logger *zap.Logger) *ExchangeRateRepo {
+	return &ExchangeRateRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (er *ExchangeRateRepo) GetExchangeRateAt(ctx context.Context, currency model.Currency, at time.Time) (*model.ExchangeRate, error) {
+	var rate model.ExchangeRate
+	err := conn(ctx, er.db).QueryRow(ctx, `
+	SELECT rate_id, currency, rate_micros, effective_from, created_at
+	FROM exchange_rates
+	WHERE currency = $1 AND effective_from <= $2
+	ORDER BY effective_from DESC
+	LIMIT 1
+	`, string(currency), at).Scan(&rate.RateID, &rate.Currency, &rate.Rate, &rate.EffectiveFrom, &rate.CreatedAt)
+	if err == pgx.ErrNoRows {
+		info := "no exchange rate in effect"
+		utils.Log(ctx, er.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("currency", string(currency)), zap.Time("at", at))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed to get exchange rate"
+		utils.Log(ctx, er.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return &rate, nil
+}
+
+func (er *ExchangeRateRepo) ListExchangeRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	rows, err := conn(ctx, er.db).Query(ctx, `
+	SELECT rate_id, currency, rate_micros, effective_from, created_at
+	FROM exchange_rates
+	ORDER BY currency, effective_from
+	`)
+	if err != nil {
+		info := "failed to list exchange rates"
+		utils.Log(ctx, er.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	rates := []model.ExchangeRate{}
+	for rows.Next() {
+		var rate model.ExchangeRate
+		if err := rows.Scan(&rate.RateID, &rate.Currency, &rate.Rate, &rate.EffectiveFrom, &rate.CreatedAt); err != nil {
+			info := "failed while scanning exchange rates"
+			utils.Log(ctx, er.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading exchange rates"
+		utils.Log(ctx, er.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return rates, nil
+}
+
+func (er *ExchangeRateRepo) CreateExchangeRate(ctx context.Context, rate *model.ExchangeRate) error {
+	err := withinTransaction(ctx, er.db, er.logger, func(ctx context.Context) error {
+		tag, err := conn(ctx, er.db).Exec(ctx, `
+		INSERT INTO exchange_rates (rate_id, currency, rate_micros, effective_from, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (currency, effective_from) DO NOTHING
+		`, rate.RateID, string(rate.Currency), int64(rate.Rate), rate.EffectiveFrom, rate.CreatedAt)
+		if err != nil {
+			info := "failed to add exchange rate"
+			utils.Log(ctx, er.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		if tag.RowsAffected() == 0 {
+			info := "exchange rate with the same effective date exists"
+			utils.Log(ctx, er.logger).Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("currency", string(rate.Currency)))
+			return fmt.Errorf("%s: %w", info, utils.ErrExists)
+		}
+		return recordAudit(ctx, conn(ctx, er.db), er.logger, &model.AuditEvent{
+			OccurredAt: rate.CreatedAt,
+			Action:     model.AuditExchangeRateCreated,
+			EntityType: "exchange_rate",
+			EntityID:   rate.RateID.String(),
+			After:      rate,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	utils.Log(ctx, er.logger).Info("exchange rate created", zap.String("currency", string(rate.Currency)), zap.Stringer("rate", rate.Rate),
+		zap.Time("effective_from", rate.EffectiveFrom))
+	return nil
+}
+
+// inCurrency labels amounts read from columns that don't carry their own
+// currency.
+func inCurrency(currency model.Currency, amounts ...*model.Money) {
+	for _, m := range amounts {
+		m.Currency = currency
+	}
+}
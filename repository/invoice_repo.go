@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type InvoiceRepoImpl interface {
+	GetInvoiceCandidates(ctx context.Context, start, end time.Time) ([]model.InvoiceCandidate, error)
+	SummarizeTransactions(ctx context.Context, billingID uuid.UUID, start, end time.Time) ([]model.TransactionSummary, error)
+	CreateInvoice(ctx context.Context, invoice *model.Invoice) error
+	ListInvoices(ctx context.Context, clientID uuid.UUID) ([]model.Invoice, error)
+	GetInvoice(ctx context.Context, clientID, invoiceID uuid.UUID) (*model.Invoice, error)
+	UpdateInvoiceStatus(ctx context.Context, invoiceID uuid.UUID, status string) (*model.Invoice, error)
+}
+
+type InvoiceRepo struct {
+	db     *pgxpool.Pool
+	clock  utils.Clock
+	logger *zap.Logger
+}
+
+func NewInvoiceRepo(db *pgxpool.Pool, clock utils.Clock, logger *zap.Logger) *InvoiceRepo {
+	return &InvoiceRepo{
+		db:     db,
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+const invoiceColumns = `
+	i.invoice_id, i.invoice_number, i.client_id, c.email, COALESCE(i.tax_id, ''), i.billing_id, i.period_start, i.period_end,
+	i.status, i.currency, i.subtotal, i.tax_total, i.total, i.created_at, i.updated_at`
+
+func scanInvoice(row pgx.Row, invoice *model.Invoice) error {
+	var currency model.Currency
+	err := row.Scan(&invoice.InvoiceID, &invoice.InvoiceNumber, &invoice.ClientID, &invoice.Email, &invoice.TaxID,
+		&invoice.BillingID, &invoice.PeriodStart, &invoice.PeriodEnd, &invoice.Status, &currency,
+		&invoice.Subtotal, &invoice.TaxTotal, &invoice.Total, &invoice.CreatedAt, &invoice.UpdatedAt)
+	inCurrency(currency, &invoice.Subtotal, &invoice.TaxTotal, &invoice.Total)
+	return err
+}
+
+func (ir *InvoiceRepo) GetInvoiceCandidates(ctx context.Context, start, end time.Time) ([]model.InvoiceCandidate, error) {
+	rows, err := conn(ctx, ir.db).Query(ctx, `
+	SELECT b.billing_id, b.client_id, COALESCE(c.plan, ''), COALESCE(c.tax_id, ''), c.currency, b.down_payment, c.created_at
+	FROM billings b
+	JOIN clients c ON c.client_id = b.client_id
+	WHERE NOT EXISTS (
+	  SELECT 1 FROM invoices i WHERE i.billing_id = b.billing_id AND i.period_start = $1
+	)
+	AND (
+	  (c.created_at >= $1 AND c.created_at < $2)
+	  OR EXISTS (
+	    SELECT 1 FROM transactions t
+	    WHERE t.billing_id = b.billing_id AND t.created_at >= $1 AND t.created_at < $2
+	  )
+	)
+	`, start, end)
+	if err != nil {
+		info := "failed to get invoice candidates"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	var candidates []model.InvoiceCandidate
+	for rows.Next() {
+		var candidate model.InvoiceCandidate
+		err := rows.Scan(&candidate.BillingID, &candidate.ClientID, &candidate.Plan, &candidate.TaxID, &candidate.Currency, &candidate.DownPayment, &candidate.ClientCreated)
+		if err != nil {
+			info := "failed while scanning invoice candidates"
+			utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		inCurrency(candidate.Currency, &candidate.DownPayment)
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading invoice candidates"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return candidates, nil
+}
+
+func (ir *InvoiceRepo) SummarizeTransactions(ctx context.Context, billingID uuid.UUID, start, end time.Time) ([]model.TransactionSummary, error) {
+	rows, err := conn(ctx, ir.db).Query(ctx, `
+	SELECT type, currency, COUNT(*), COALESCE(SUM(amount), 0)::BIGINT, COALESCE(SUM(tax_amount), 0)::BIGINT
+	FROM transactions
+	WHERE billing_id = $1 AND created_at >= $2 AND created_at < $3
+	GROUP BY type, currency
+	ORDER BY type
+	`, billingID, start, end)
+	if err != nil {
+		info := "failed to summarize transactions"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info),
+			zap.String("billing_id", billingID.String()), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	var summaries []model.TransactionSummary
+	for rows.Next() {
+		var summary model.TransactionSummary
+		var currency model.Currency
+		if err := rows.Scan(&summary.Type, &currency, &summary.Count, &summary.Amount, &summary.TaxAmount); err != nil {
+			info := "failed while scanning transaction summary"
+			utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		inCurrency(currency, &summary.Amount, &summary.TaxAmount)
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading transaction summary"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return summaries, nil
+}
+
+// CreateInvoice assigns the next invoice number and stores the invoice with its
+// lines. The counter row stays locked until tx ends, so numbers have no gaps.
+func (ir *InvoiceRepo) CreateInvoice(ctx context.Context, invoice *model.Invoice) error {
+	var number int64
+	err := conn(ctx, ir.db).QueryRow(ctx, `
+	INSERT INTO invoice_counter (id, last_number) VALUES (TRUE, 1)
+	ON CONFLICT (id) DO UPDATE SET last_number = invoice_counter.last_number + 1
+	RETURNING last_number
+	`).Scan(&number)
+	if err != nil {
+		info := "failed to reserve invoice number"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	invoice.InvoiceNumber = fmt.Sprintf("INV-%s-%06d", invoice.PeriodStart.Format("200601"), number)
+
+	_, err = conn(ctx, ir.db).Exec(ctx, `
+	INSERT INTO invoices
+	(invoice_id, invoice_number, client_id, tax_id, billing_id, period_start, period_end, status,
+	 currency, subtotal, tax_total, total, created_at, updated_at)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, invoice.InvoiceID, invoice.InvoiceNumber, invoice.ClientID, invoice.TaxID, invoice.BillingID, invoice.PeriodStart,
+		invoice.PeriodEnd, invoice.Status, string(invoice.Total.Currency), invoice.Subtotal, invoice.TaxTotal, invoice.Total,
+		invoice.CreatedAt, invoice.UpdatedAt)
+	if err != nil {
+		info := "failed to add invoice"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info),
+			zap.String("billing_id", invoice.BillingID.String()), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+
+	for _, line := range invoice.Lines {
+		_, err = conn(ctx, ir.db).Exec(ctx, `
+		INSERT INTO invoice_lines
+		(line_id, invoice_id, type, description, quantity, unit_price, amount, tax_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, line.LineID, invoice.InvoiceID, line.Type, line.Description, line.Quantity, line.UnitPrice,
+			line.Amount, line.TaxAmount)
+		if err != nil {
+			info := "failed to add invoice line"
+			utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info),
+				zap.String("invoice_id", invoice.InvoiceID.String()), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+	}
+	return nil
+}
+
+func (ir *InvoiceRepo) ListInvoices(ctx context.Context, clientID uuid.UUID) ([]model.Invoice, error) {
+	rows, err := conn(ctx, ir.db).Query(ctx, `
+	SELECT`+invoiceColumns+`
+	FROM invoices i
+	JOIN clients c ON c.client_id = i.client_id
+	WHERE i.client_id = $1
+	ORDER BY i.period_start DESC, i.invoice_number DESC
+	`, clientID)
+	if err != nil {
+		info := "failed to list invoices"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	invoices := []model.Invoice{}
+	for rows.Next() {
+		var invoice model.Invoice
+		if err := scanInvoice(rows, &invoice); err != nil {
+			info := "failed while scanning invoices"
+			utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		invoices = append(invoices, invoice)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading invoices"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+
+	for i := range invoices {
+		if invoices[i].Lines, err = ir.getInvoiceLines(ctx, invoices[i].InvoiceID, invoices[i].Total.Currency); err != nil {
+			return nil, err
+		}
+	}
+	return invoices, nil
+}
+
+func (ir *InvoiceRepo) GetInvoice(ctx context.Context, clientID, invoiceID uuid.UUID) (*model.Invoice, error) {
+	var invoice model.Invoice
+	err := scanInvoice(conn(ctx, ir.db).QueryRow(ctx, `
+	SELECT`+invoiceColumns+`
+	FROM invoices i
+	JOIN clients c ON c.client_id = i.client_id
+	WHERE i.client_id = $1 AND i.invoice_id = $2
+	`, clientID, invoiceID), &invoice)
+	if err == pgx.ErrNoRows {
+		info := "invoice not found"
+		utils.Log(ctx, ir.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("invoice_id", invoiceID.String()))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed while scanning invoice"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	if invoice.Lines, err = ir.getInvoiceLines(ctx, invoice.InvoiceID, invoice.Total.Currency); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// UpdateInvoiceStatus moves an open invoice to status. Paid and void invoices are final.
+func (ir *InvoiceRepo) UpdateInvoiceStatus(ctx context.Context, invoiceID uuid.UUID, status string) (*model.Invoice, error) {
+	var clientID uuid.UUID
+	err := withinTransaction(ctx, ir.db, ir.logger, func(ctx context.Context) error {
+		now := ir.clock.Now()
+		if err := ir.setInvoiceStatus(ctx, invoiceID, status, now, &clientID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, conn(ctx, ir.db), ir.logger, &model.AuditEvent{
+			OccurredAt: now,
+			Action:     model.AuditInvoiceStatus,
+			EntityType: "invoice",
+			EntityID:   invoiceID.String(),
+			ClientID:   &clientID,
+			Before:     map[string]string{"status": model.InvoiceOpen},
+			After:      map[string]string{"status": status},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ir.GetInvoice(ctx, clientID, invoiceID)
+}
+
+func (ir *InvoiceRepo) setInvoiceStatus(ctx context.Context, invoiceID uuid.UUID, status string, now time.Time, clientID *uuid.UUID) error {
+	err := conn(ctx, ir.db).QueryRow(ctx, `
+	UPDATE invoices SET status = $1, updated_at = $2
+	WHERE invoice_id = $3 AND status = $4
+	RETURNING client_id
+	`, status, now, invoiceID, model.InvoiceOpen).Scan(clientID)
+	if err == pgx.ErrNoRows {
+		var current string
+		err = conn(ctx, ir.db).QueryRow(ctx, `SELECT status FROM invoices WHERE invoice_id = $1`, invoiceID).Scan(&current)
+		if err == pgx.ErrNoRows {
+			info := "invoice not found"
+			utils.Log(ctx, ir.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("invoice_id", invoiceID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+		} else if err == nil {
+			info := fmt.Sprintf("invoice is already %s", current)
+			utils.Log(ctx, ir.logger).Warn(utils.ErrBadRequest.Error(), zap.String("warn", info), zap.String("invoice_id", invoiceID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrBadRequest)
+		}
+	}
+	if err != nil {
+		info := "failed to update invoice status"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return nil
+}
+
+func (ir *InvoiceRepo) getInvoiceLines(ctx context.Context, invoiceID uuid.UUID, currency model.Currency) ([]model.InvoiceLine, error) {
+	rows, err := conn(ctx, ir.db).Query(ctx, `
+	SELECT line_id, invoice_id, type, description, quantity, unit_price, amount, tax_amount
+	FROM invoice_lines
+	WHERE invoice_id = $1
+	ORDER BY type
+	`, invoiceID)
+	if err != nil {
+		info := "failed to get invoice lines"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	lines := []model.InvoiceLine{}
+	for rows.Next() {
+		var line model.InvoiceLine
+		err := rows.Scan(&line.LineID, &line.InvoiceID, &line.Type, &line.Description,
+			&line.Quantity, &line.UnitPrice, &line.Amount, &line.TaxAmount)
+		if err != nil {
+			info := "failed while scanning invoice lines"
+			utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		inCurrency(currency, &line.UnitPrice, &line.Amount, &line.TaxAmount)
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading invoice lines"
+		utils.Log(ctx, ir.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return lines, nil
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+type ClientRepo struct {
+	store *Store
+}
+
+func NewClientRepo(store *Store) *ClientRepo {
+	return &ClientRepo{store: store}
+}
+
+func (cr *ClientRepo) CreateClientRepo(ctx context.Context, client *model.Client, billing *model.Billing) error {
+	defer cr.store.lock(ctx)()
+	for _, existing := range cr.store.clients {
+		if existing.Email == client.Email {
+			return fmt.Errorf("email exists: %w", utils.ErrExists)
+		}
+	}
+	stored := *billing
+	stored.ClientID = client.ClientID
+	cr.store.clients[client.ClientID] = *client
+	cr.store.billings[billing.BillingID] = stored
+	cr.store.billingIDs[client.ClientID] = billing.BillingID
+	return nil
+}
+
+func (cr *ClientRepo) GetClientInfoRepo(ctx context.Context, clientID uuid.UUID) (*res.ClientInfo, error) {
+	defer cr.store.lock(ctx)()
+	client, ok := cr.store.clients[clientID]
+	if !ok {
+		return nil, fmt.Errorf("client id not found: %w", utils.ErrNotFound)
+	}
+	info := &res.ClientInfo{
+		ClientID:      client.ClientID,
+		Email:         client.Email,
+		Suspended:     client.Suspended,
+		Plan:          client.Plan,
+		Currency:      client.Currency,
+		Balance:       client.Balance,
+		TaxID:         client.TaxID,
+		TaxExempt:     client.TaxExempt,
+		ClientCreated: client.CreatedAt,
+		ClientUpdated: client.UpdatedAt,
+	}
+	if billing, ok := cr.store.billingOf(clientID); ok {
+		info.BillingID = billing.BillingID
+		info.CPU = billing.CPU
+		info.RAM = billing.RAM
+		info.Storage = billing.Storage
+		info.DownPayment = billing.DownPayment
+		info.MonthlyFee = billing.MonthlyFee
+		info.CostPerHour = billing.CostPerHour
+		info.TotalFee = billing.TotalFee
+		info.Uptime = billing.Uptime
+		info.BillingCreated = billing.CreatedAt
+		info.BillingUpdated = billing.UpdatedAt
+	}
+	return info, nil
+}
+
+func (cr *ClientRepo) ListClientsRepo(ctx context.Context, filter *req.ClientFilter) (*res.ClientList, error) {
+	defer cr.store.lock(ctx)()
+
+	var matched []res.ClientSummary
+	for _, client := range cr.store.clients {
+		switch {
+		case filter.Suspended != nil && client.Suspended != *filter.Suspended,
+			filter.Plan != "" && client.Plan != filter.Plan,
+			filter.Currency != "" && client.Currency != filter.Currency,
+			filter.BalanceBelow != nil && client.Balance.Amount >= *filter.BalanceBelow,
+			filter.CreatedFrom != nil && client.CreatedAt.Before(*filter.CreatedFrom),
+			filter.CreatedTo != nil && !client.CreatedAt.Before(*filter.CreatedTo):
+			continue
+		}
+		matched = append(matched, res.ClientSummary{
+			ClientID:  client.ClientID,
+			Email:     client.Email,
+			Plan:      client.Plan,
+			Suspended: client.Suspended,
+			Balance:   client.Balance,
+			CreatedAt: client.CreatedAt,
+			UpdatedAt: client.UpdatedAt,
+		})
+	}
+
+	compare := func(a, b res.ClientSummary) int {
+		var c int
+		switch filter.SortBy {
+		case req.SortBalance:
+			c = cmp.Compare(a.Balance.Amount, b.Balance.Amount)
+		case req.SortEmail:
+			c = strings.Compare(a.Email, b.Email)
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ClientID.String(), b.ClientID.String())
+		}
+		if filter.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(matched, compare)
+
+	list := &res.ClientList{Total: len(matched), Clients: []res.ClientSummary{}}
+	if filter.Cursor != nil {
+		after, err := cursorRow(filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor value: %w", utils.ErrBadRequest)
+		}
+		i, _ := slices.BinarySearchFunc(matched, after, compare)
+		for i < len(matched) && compare(matched[i], after) <= 0 {
+			i++
+		}
+		matched = matched[i:]
+	}
+	if len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+		last := matched[len(matched)-1]
+		cursor := req.ClientCursor{SortBy: filter.SortBy, Desc: filter.Desc, ClientID: last.ClientID}
+		switch filter.SortBy {
+		case req.SortBalance:
+			cursor.Value = strconv.FormatInt(last.Balance.Amount, 10)
+		case req.SortEmail:
+			cursor.Value = last.Email
+		default:
+			cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		list.NextCursor = cursor.Encode()
+	}
+	list.Clients = append(list.Clients, matched...)
+	return list, nil
+}
+
+// cursorRow turns the cursor back into a row that sorts where the last row
+// of the previous page was.
+func cursorRow(filter *req.ClientFilter) (res.ClientSummary, error) {
+	row := res.ClientSummary{ClientID: filter.Cursor.ClientID}
+	var err error
+	switch filter.SortBy {
+	case req.SortBalance:
+		row.Balance.Amount, err = strconv.ParseInt(filter.Cursor.Value, 10, 64)
+	case req.SortEmail:
+		row.Email = filter.Cursor.Value
+	default:
+		row.CreatedAt, err = time.Parse(time.RFC3339Nano, filter.Cursor.Value)
+	}
+	return row, err
+}
+
+func (cr *ClientRepo) UpdateClientTaxRepo(ctx context.Context, clientID uuid.UUID, tax *req.ClientTax) error {
+	defer cr.store.lock(ctx)()
+	client, ok := cr.store.clients[clientID]
+	if !ok {
+		return fmt.Errorf("client id not found: %w", utils.ErrNotFound)
+	}
+	client.TaxID = tax.TaxID
+	client.TaxExempt = tax.TaxExempt
+	cr.store.clients[clientID] = client
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+type ExchangeRateRepo struct {
+	mu    sync.Mutex
+	rates []model.ExchangeRate
+}
+
+func NewExchangeRateRepo(rates ...model.ExchangeRate) *ExchangeRateRepo {
+	return &ExchangeRateRepo{rates: rates}
+}
+
+func (er *ExchangeRateRepo) GetExchangeRateAt(ctx context.Context, currency model.Currency, at time.Time) (*model.ExchangeRate, error) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	var found *model.ExchangeRate
+	for i, rate := range er.rates {
+		if rate.Currency != currency || rate.EffectiveFrom.After(at) {
+			continue
+		}
+		if found == nil || rate.EffectiveFrom.After(found.EffectiveFrom) {
+			found = &er.rates[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no exchange rate in effect: %w", utils.ErrNotFound)
+	}
+	rate := *found
+	return &rate, nil
+}
+
+func (er *ExchangeRateRepo) ListExchangeRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	rates := slices.Clone(er.rates)
+	slices.SortFunc(rates, func(a, b model.ExchangeRate) int {
+		return cmp.Or(cmp.Compare(a.Currency, b.Currency), a.EffectiveFrom.Compare(b.EffectiveFrom))
+	})
+	return rates, nil
+}
+
+func (er *ExchangeRateRepo) CreateExchangeRate(ctx context.Context, rate *model.ExchangeRate) error {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	for _, existing := range er.rates {
+		if existing.Currency == rate.Currency && existing.EffectiveFrom.Equal(rate.EffectiveFrom) {
+			return fmt.Errorf("exchange rate with the same effective date exists: %w", utils.ErrExists)
+		}
+	}
+	er.rates = append(er.rates, *rate)
+	return nil
+}
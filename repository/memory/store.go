@@ -0,0 +1,98 @@
+// Package memory implements the repository interfaces on top of plain Go
+// maps, for running the service layer without Postgres.
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	_ repository.TransactorImpl               = (*Store)(nil)
+	_ repository.ClientRepoImpl               = (*ClientRepo)(nil)
+	_ repository.TransactionSchedulerRepoImpl = (*TransactionSchedulerRepo)(nil)
+	_ repository.TaxRepoImpl                  = (*TaxRepo)(nil)
+	_ repository.ExchangeRateRepoImpl         = (*ExchangeRateRepo)(nil)
+)
+
+// Store holds the data shared by the in-memory repositories. A transaction
+// holds the store exclusively until it ends, which is stricter than Postgres
+// row locking but gives the same guarantees to callers. Audit events are
+// not kept.
+type Store struct {
+	mu       sync.Mutex
+	clients  map[uuid.UUID]model.Client
+	billings map[uuid.UUID]model.Billing
+	// billingIDs maps each client to its billing.
+	billingIDs   map[uuid.UUID]uuid.UUID
+	transactions []model.Transaction
+}
+
+func NewStore() *Store {
+	return &Store{
+		clients:    make(map[uuid.UUID]model.Client),
+		billings:   make(map[uuid.UUID]model.Billing),
+		billingIDs: make(map[uuid.UUID]uuid.UUID),
+	}
+}
+
+type txKey struct{}
+
+type snapshot struct {
+	clients      map[uuid.UUID]model.Client
+	billings     map[uuid.UUID]model.Billing
+	billingIDs   map[uuid.UUID]uuid.UUID
+	transactions []model.Transaction
+}
+
+// lock takes the store for a single call, unless ctx belongs to a
+// transaction that already holds it.
+func (s *Store) lock(ctx context.Context) func() {
+	if s.inTransaction(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) inTransaction(ctx context.Context) bool {
+	store, _ := ctx.Value(txKey{}).(*Store)
+	return store == s
+}
+
+// WithinTransaction implements repository.TransactorImpl. Changes made by fn
+// are discarded when it returns an error; nested calls behave like savepoints.
+func (s *Store) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !s.inTransaction(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ctx = context.WithValue(ctx, txKey{}, s)
+	}
+	snap := snapshot{
+		clients:      maps.Clone(s.clients),
+		billings:     maps.Clone(s.billings),
+		billingIDs:   maps.Clone(s.billingIDs),
+		transactions: slices.Clone(s.transactions),
+	}
+	if err := fn(ctx); err != nil {
+		s.clients, s.billings, s.billingIDs, s.transactions = snap.clients, snap.billings, snap.billingIDs, snap.transactions
+		return err
+	}
+	return nil
+}
+
+// billingOf returns the billing of a client. Callers must hold the store.
+func (s *Store) billingOf(clientID uuid.UUID) (model.Billing, bool) {
+	billingID, ok := s.billingIDs[clientID]
+	if !ok {
+		return model.Billing{}, false
+	}
+	billing, ok := s.billings[billingID]
+	return billing, ok
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+var errAbort = errors.New("abort")
+
+func newClient(email string, balance model.Money, now time.Time) (*model.Client, *model.Billing) {
+	client := &model.Client{
+		ClientID: uuid.New(), Email: email, Plan: model.BasicBilling, Currency: balance.Currency,
+		Balance: balance, CreatedAt: now, UpdatedAt: now,
+	}
+	billing := &model.Billing{
+		BillingID: uuid.New(), ClientID: client.ClientID, CPU: 1, RAM: 1024, Storage: 8,
+		CostPerHour: model.NewMoney(2000, balance.Currency), TotalFee: model.NewMoney(0, balance.Currency),
+		CreatedAt: now, UpdatedAt: now,
+	}
+	return client, billing
+}
+
+func TestStoreWithinTransactionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	clock := utils.NewFakeClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	store := NewStore()
+	clients := NewClientRepo(store)
+	scheduler := NewTransactionSchedulerRepo(store, clock)
+
+	existing, existingBilling := newClient("existing@example.com", model.Rupiah(100000), clock.Now())
+	if err := clients.CreateClientRepo(ctx, existing, existingBilling); err != nil {
+		t.Fatal(err)
+	}
+	before, err := clients.GetClientInfoRepo(ctx, existing.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+
+	added, addedBilling := newClient("added@example.com", model.Rupiah(50000), clock.Now())
+	err = store.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := clients.CreateClientRepo(ctx, added, addedBilling); err != nil {
+			return err
+		}
+		if _, err := scheduler.UpdateBalance(ctx, existing.ClientID, model.Rupiah(-2000), model.ReasonHourlyCharge); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateTotalFee(ctx, existingBilling.BillingID, model.Rupiah(2000)); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateClientInfo(ctx, existing.ClientID); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateBillingInfo(ctx, existingBilling.BillingID, 42); err != nil {
+			return err
+		}
+		if err := scheduler.SuspendClient(ctx, existing.ClientID, model.ReasonNegativeBalance); err != nil {
+			return err
+		}
+		if err := clients.UpdateClientTaxRepo(ctx, existing.ClientID, &req.ClientTax{TaxExempt: true}); err != nil {
+			return err
+		}
+		if err := scheduler.InsertTransaction(ctx, &model.Transaction{
+			TransactionID: uuid.New(), ClientID: existing.ClientID, BillingID: existingBilling.BillingID,
+			Type: model.HourlyChargeTransaction, Amount: model.Rupiah(2000),
+		}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err = %v, want %v", err, errAbort)
+	}
+
+	after, err := clients.GetClientInfoRepo(ctx, existing.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *after != *before {
+		t.Errorf("client changed by a rolled back transaction:\n got %+v\nwant %+v", after, before)
+	}
+	if store.billings[existingBilling.BillingID].TaxCarry != 0 {
+		t.Error("tax carry kept after rollback")
+	}
+	if _, err := clients.GetClientInfoRepo(ctx, added.ClientID); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("client created in a rolled back transaction: %v", err)
+	}
+	if _, ok := store.billings[addedBilling.BillingID]; ok {
+		t.Error("billing created in a rolled back transaction")
+	}
+	if n := len(scheduler.Transactions(ctx)); n != 0 {
+		t.Errorf("%d transactions kept after rollback", n)
+	}
+}
+
+// A failing nested call only undoes its own writes, like a savepoint.
+func TestStoreWithinTransactionNested(t *testing.T) {
+	ctx := context.Background()
+	clock := utils.NewFakeClock(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	store := NewStore()
+	clients := NewClientRepo(store)
+	scheduler := NewTransactionSchedulerRepo(store, clock)
+	client, billing := newClient("nested@example.com", model.Rupiah(100000), clock.Now())
+	if err := clients.CreateClientRepo(ctx, client, billing); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := scheduler.UpdateBalance(ctx, client.ClientID, model.Rupiah(-1000), model.AdjustmentOther); err != nil {
+			return err
+		}
+		err := store.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, err := scheduler.UpdateBalance(ctx, client.ClientID, model.Rupiah(-500), model.AdjustmentOther); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("nested err = %v, want %v", err, errAbort)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := clients.GetClientInfoRepo(ctx, client.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Balance != model.Rupiah(99000) {
+		t.Errorf("balance = %s, want only the outer change kept", info.Balance)
+	}
+}
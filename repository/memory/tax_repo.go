@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+type TaxRepo struct {
+	mu    sync.Mutex
+	rules []model.TaxRule
+}
+
+func NewTaxRepo(rules ...model.TaxRule) *TaxRepo {
+	return &TaxRepo{rules: rules}
+}
+
+func (tr *TaxRepo) GetTaxRuleAt(ctx context.Context, name string, at time.Time) (*model.TaxRule, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var found *model.TaxRule
+	for i, rule := range tr.rules {
+		if rule.Name != name || rule.EffectiveFrom.After(at) || (rule.EffectiveTo != nil && !rule.EffectiveTo.After(at)) {
+			continue
+		}
+		if found == nil || rule.EffectiveFrom.After(found.EffectiveFrom) {
+			found = &tr.rules[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no tax rule in effect: %w", utils.ErrNotFound)
+	}
+	rule := *found
+	return &rule, nil
+}
+
+func (tr *TaxRepo) ListTaxRules(ctx context.Context) ([]model.TaxRule, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	rules := slices.Clone(tr.rules)
+	slices.SortFunc(rules, func(a, b model.TaxRule) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), a.EffectiveFrom.Compare(b.EffectiveFrom))
+	})
+	return rules, nil
+}
+
+func (tr *TaxRepo) CreateTaxRule(ctx context.Context, rule *model.TaxRule) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, existing := range tr.rules {
+		if existing.Name == rule.Name && existing.EffectiveFrom.Equal(rule.EffectiveFrom) {
+			return fmt.Errorf("tax rule with the same effective date exists: %w", utils.ErrExists)
+		}
+	}
+	tr.rules = append(tr.rules, *rule)
+	return nil
+}
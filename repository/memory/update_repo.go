@@ -0,0 +1,232 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+)
+
+type TransactionSchedulerRepo struct {
+	store *Store
+	clock utils.Clock
+}
+
+func NewTransactionSchedulerRepo(store *Store, clock utils.Clock) *TransactionSchedulerRepo {
+	return &TransactionSchedulerRepo{
+		store: store,
+		clock: clock,
+	}
+}
+
+// due lists active clients last charged at or before dueBefore, oldest first.
+// Callers must hold the store.
+func (hr *TransactionSchedulerRepo) due(dueBefore time.Time) []model.UpdateClient {
+	var clients []model.UpdateClient
+	for _, client := range hr.store.clients {
+		if data, ok := hr.dueClient(client, dueBefore); ok {
+			clients = append(clients, data)
+		}
+	}
+	slices.SortFunc(clients, func(a, b model.UpdateClient) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return clients
+}
+
+// dueClient reports whether client is active and last charged at or before
+// dueBefore, and if so returns what a charge needs of it. Callers must hold
+// the store.
+func (hr *TransactionSchedulerRepo) dueClient(client model.Client, dueBefore time.Time) (model.UpdateClient, bool) {
+	if client.Suspended || client.UpdatedAt.After(dueBefore) {
+		return model.UpdateClient{}, false
+	}
+	billing, ok := hr.store.billingOf(client.ClientID)
+	if !ok {
+		return model.UpdateClient{}, false
+	}
+	return model.UpdateClient{
+		ClientID:    client.ClientID,
+		BillingID:   billing.BillingID,
+		Suspended:   client.Suspended,
+		TaxExempt:   client.TaxExempt,
+		Plan:        client.Plan,
+		Currency:    client.Currency,
+		Balance:     client.Balance,
+		MonthlyFee:  billing.MonthlyFee,
+		CostPerHour: billing.CostPerHour,
+		TotalFee:    billing.TotalFee,
+		Uptime:      billing.Uptime,
+		TaxCarry:    billing.TaxCarry,
+		UpdatedAt:   client.UpdatedAt,
+	}, true
+}
+
+func (hr *TransactionSchedulerRepo) GetActiveClient(ctx context.Context, dueBefore time.Time) ([]model.UpdateClient, error) {
+	defer hr.store.lock(ctx)()
+	return hr.due(dueBefore), nil
+}
+
+func (hr *TransactionSchedulerRepo) ClaimClient(ctx context.Context, clientID uuid.UUID, dueBefore time.Time) (*model.UpdateClient, error) {
+	defer hr.store.lock(ctx)()
+	if data, ok := hr.dueClient(hr.store.clients[clientID], dueBefore); ok {
+		return &data, nil
+	}
+	return nil, fmt.Errorf("client not due or claimed elsewhere: %w", utils.ErrNotFound)
+}
+
+func (hr *TransactionSchedulerRepo) UpdateBalance(ctx context.Context, clientID uuid.UUID, delta model.Money, reason string) (model.Money, error) {
+	defer hr.store.lock(ctx)()
+	client, ok := hr.store.clients[clientID]
+	if !ok {
+		return model.Money{}, fmt.Errorf("failed to update balance: %w", utils.ErrDatabase)
+	}
+	balance, err := client.Balance.Add(delta)
+	if err != nil {
+		return model.Money{}, err
+	}
+	client.Balance = balance
+	hr.store.clients[clientID] = client
+	return client.Balance, nil
+}
+
+func (hr *TransactionSchedulerRepo) UpdateTotalFee(ctx context.Context, billingID uuid.UUID, fee model.Money) error {
+	defer hr.store.lock(ctx)()
+	billing, ok := hr.store.billings[billingID]
+	if !ok {
+		return nil
+	}
+	total, err := billing.TotalFee.Add(fee)
+	if err != nil {
+		return err
+	}
+	billing.TotalFee = total
+	hr.store.billings[billingID] = billing
+	return nil
+}
+
+func (hr *TransactionSchedulerRepo) UpdateClientInfo(ctx context.Context, clientID uuid.UUID) error {
+	defer hr.store.lock(ctx)()
+	client, ok := hr.store.clients[clientID]
+	if ok {
+		client.UpdatedAt = hr.clock.Now()
+		hr.store.clients[clientID] = client
+	}
+	return nil
+}
+
+func (hr *TransactionSchedulerRepo) UpdateBillingInfo(ctx context.Context, billingID uuid.UUID, taxCarry int64) error {
+	defer hr.store.lock(ctx)()
+	billing, ok := hr.store.billings[billingID]
+	if ok {
+		billing.Uptime++
+		billing.TaxCarry = taxCarry
+		billing.UpdatedAt = hr.clock.Now()
+		hr.store.billings[billingID] = billing
+	}
+	return nil
+}
+
+func (hr *TransactionSchedulerRepo) SuspendClient(ctx context.Context, clientID uuid.UUID, reason string) error {
+	defer hr.store.lock(ctx)()
+	client, ok := hr.store.clients[clientID]
+	if ok {
+		client.Suspended = true
+		hr.store.clients[clientID] = client
+	}
+	return nil
+}
+
+func (hr *TransactionSchedulerRepo) InsertTransaction(ctx context.Context, transaction *model.Transaction) error {
+	defer hr.store.lock(ctx)()
+	_, clientOK := hr.store.clients[transaction.ClientID]
+	_, billingOK := hr.store.billings[transaction.BillingID]
+	if !clientOK || !billingOK {
+		return fmt.Errorf("failed to record transaction: %w", utils.ErrDatabase)
+	}
+	hr.store.transactions = append(hr.store.transactions, *transaction)
+	return nil
+}
+
+func (hr *TransactionSchedulerRepo) ChargeDueBatch(ctx context.Context, dueBefore, now time.Time, limit, taxRateBps int, rates map[model.Currency]model.Rate) ([]model.ChargeResult, error) {
+	defer hr.store.lock(ctx)()
+	due := slices.DeleteFunc(hr.due(dueBefore), func(client model.UpdateClient) bool {
+		_, ok := rates[client.Currency]
+		return !ok
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	tax := &model.TaxRule{RateBps: taxRateBps}
+
+	var results []model.ChargeResult
+	for _, data := range due {
+		result := model.ChargeResult{
+			ClientID:     data.ClientID,
+			BillingID:    data.BillingID,
+			Plan:         data.Plan,
+			Amount:       data.CostPerHour,
+			MonthlyFee:   data.MonthlyFee,
+			ExchangeRate: rates[data.Currency],
+		}
+		result.TaxAmount = model.Money{Currency: data.CostPerHour.Currency}
+		taxCarry := data.TaxCarry
+		if !data.TaxExempt {
+			amount, carry, err := tax.Calculate(data.CostPerHour, data.TaxCarry)
+			if err != nil {
+				return nil, err
+			}
+			result.TaxAmount, taxCarry = amount, carry
+		}
+		charge, err := result.Amount.Add(result.TaxAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		client := hr.store.clients[data.ClientID]
+		if client.Balance, err = client.Balance.Sub(charge); err != nil {
+			return nil, err
+		}
+		client.Suspended = client.Balance.IsNegative()
+		client.UpdatedAt = now
+		hr.store.clients[data.ClientID] = client
+
+		billing := hr.store.billings[data.BillingID]
+		if billing.TotalFee, err = billing.TotalFee.Add(result.Amount); err != nil {
+			return nil, err
+		}
+		billing.Uptime++
+		billing.TaxCarry = taxCarry
+		billing.UpdatedAt = now
+		hr.store.billings[data.BillingID] = billing
+
+		hr.store.transactions = append(hr.store.transactions, model.Transaction{
+			TransactionID: uuid.New(),
+			ClientID:      data.ClientID,
+			BillingID:     data.BillingID,
+			Type:          model.HourlyChargeTransaction,
+			Amount:        result.Amount,
+			TaxAmount:     result.TaxAmount,
+			BalanceAfter:  client.Balance,
+			ExchangeRate:  result.ExchangeRate,
+			PeriodStart:   data.UpdatedAt,
+			PeriodEnd:     now,
+			CreatedAt:     now,
+		})
+
+		result.BalanceAfter = client.Balance
+		result.Suspended = client.Suspended
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Transactions returns a copy of every recorded transaction, oldest first.
+func (hr *TransactionSchedulerRepo) Transactions(ctx context.Context) []model.Transaction {
+	defer hr.store.lock(ctx)()
+	return slices.Clone(hr.store.transactions)
+}
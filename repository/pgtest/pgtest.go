@@ -0,0 +1,64 @@
+// Package pgtest gives tests a migrated PostgreSQL schema of their own.
+package pgtest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bagasadiii/maxcloud_vps/migration"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Open connects to the database in TESTDATABASELINK and returns a pool whose
+// connections use a new, fully migrated schema. The schema is dropped when
+// the test ends, so packages testing in parallel never see each other's
+// rows. The test is skipped when TESTDATABASELINK is not set.
+func Open(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TESTDATABASELINK")
+	if url == "" {
+		t.Skip("TESTDATABASELINK is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+		admin.Close(ctx)
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parse TESTDATABASELINK: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("open pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := migration.New(pool, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+	return pool
+}
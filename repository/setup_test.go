@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestClient builds a basic plan client and its billing, priced in the
+// currency of balance.
+func newTestClient(email string, balance model.Money, now time.Time) (*model.Client, *model.Billing) {
+	prices := model.Prices[balance.Currency]
+	client := &model.Client{
+		ClientID: uuid.New(), Email: email, Plan: model.BasicBilling, Currency: balance.Currency,
+		Balance: balance, CreatedAt: now, UpdatedAt: now,
+	}
+	billing := &model.Billing{
+		BillingID: uuid.New(), ClientID: client.ClientID, CPU: model.Basic.CPU, RAM: model.Basic.RAM, Storage: model.Basic.Storage,
+		DownPayment: model.NewMoney(prices.DownPayment[model.BasicBilling], balance.Currency),
+		MonthlyFee:  model.NewMoney(720*(prices.CPU+prices.RAM+8*prices.Storage), balance.Currency),
+		CostPerHour: model.NewMoney(prices.CPU+prices.RAM+8*prices.Storage, balance.Currency),
+		TotalFee:    model.NewMoney(0, balance.Currency),
+		CreatedAt:   now, UpdatedAt: now,
+	}
+	return client, billing
+}
+
+// createTestClient stores a new basic plan client in db.
+func createTestClient(t testing.TB, db *pgxpool.Pool, email string, balance model.Money) (*model.Client, *model.Billing) {
+	t.Helper()
+	client, billing := newTestClient(email, balance, epoch)
+	if err := NewClientRepo(db, zap.NewNop()).CreateClientRepo(context.Background(), client, billing); err != nil {
+		t.Fatalf("create %s: %v", email, err)
+	}
+	return client, billing
+}
+
+// count returns the number of rows in table.
+func count(t testing.TB, db *pgxpool.Pool, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(context.Background(), "SELECT count(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type TaxRepoImpl interface {
+	GetTaxRuleAt(ctx context.Context, name string, at time.Time) (*model.TaxRule, error)
+	ListTaxRules(ctx context.Context) ([]model.TaxRule, error)
+	CreateTaxRule(ctx context.Context, rule *model.TaxRule) error
+}
+
+type TaxRepo struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewTaxRepo(db *pgxpool.Pool, logger *zap.Logger) *TaxRepo {
+	return &TaxRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (tr *TaxRepo) GetTaxRuleAt(ctx context.Context, name string, at time.Time) (*model.TaxRule, error) {
+	var rule model.TaxRule
+	err := conn(ctx, tr.db).QueryRow(ctx, `
+	SELECT rule_id, name, rate_bps, effective_from, effective_to, created_at
+	FROM tax_rules
+	WHERE name = $1 AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+	ORDER BY effective_from DESC
+	LIMIT 1
+	`, name, at).Scan(&rule.RuleID, &rule.Name, &rule.RateBps, &rule.EffectiveFrom, &rule.EffectiveTo, &rule.CreatedAt)
+	if err == pgx.ErrNoRows {
+		info := "no tax rule in effect"
+		utils.Log(ctx, tr.logger).Warn(utils.ErrNotFound.Error(), zap.String("warn", info), zap.String("name", name), zap.Time("at", at))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed to get tax rule"
+		utils.Log(ctx, tr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return &rule, nil
+}
+
+func (tr *TaxRepo) ListTaxRules(ctx context.Context) ([]model.TaxRule, error) {
+	rows, err := conn(ctx, tr.db).Query(ctx, `
+	SELECT rule_id, name, rate_bps, effective_from, effective_to, created_at
+	FROM tax_rules
+	ORDER BY name, effective_from
+	`)
+	if err != nil {
+		info := "failed to list tax rules"
+		utils.Log(ctx, tr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	rules := []model.TaxRule{}
+	for rows.Next() {
+		var rule model.TaxRule
+		err := rows.Scan(&rule.RuleID, &rule.Name, &rule.RateBps, &rule.EffectiveFrom, &rule.EffectiveTo, &rule.CreatedAt)
+		if err != nil {
+			info := "failed while scanning tax rules"
+			utils.Log(ctx, tr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading tax rules"
+		utils.Log(ctx, tr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return rules, nil
+}
+
+func (tr *TaxRepo) CreateTaxRule(ctx context.Context, rule *model.TaxRule) error {
+	err := withinTransaction(ctx, tr.db, tr.logger, func(ctx context.Context) error {
+		tag, err := conn(ctx, tr.db).Exec(ctx, `
+		INSERT INTO tax_rules (rule_id, name, rate_bps, effective_from, effective_to, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name, effective_from) DO NOTHING
+		`, rule.RuleID, rule.Name, rule.RateBps, rule.EffectiveFrom, rule.EffectiveTo, rule.CreatedAt)
+		if err != nil {
+			info := "failed to add tax rule"
+			utils.Log(ctx, tr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		if tag.RowsAffected() == 0 {
+			info := "tax rule with the same effective date exists"
+			utils.Log(ctx, tr.logger).Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("name", rule.Name))
+			return fmt.Errorf("%s: %w", info, utils.ErrExists)
+		}
+		return recordAudit(ctx, conn(ctx, tr.db), tr.logger, &model.AuditEvent{
+			OccurredAt: rule.CreatedAt,
+			Action:     model.AuditTaxRuleCreated,
+			EntityType: "tax_rule",
+			EntityID:   rule.RuleID.String(),
+			After:      rule,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	utils.Log(ctx, tr.logger).Info("tax rule created", zap.String("name", rule.Name), zap.Int("rate_bps", rule.RateBps),
+		zap.Time("effective_from", rule.EffectiveFrom))
+	return nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// TransactorImpl runs a unit of work atomically. Repository calls made with
+// the ctx passed to fn take part in the transaction; it commits when fn
+// returns nil and rolls back otherwise.
+type TransactorImpl interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// DBTX is what pgxpool.Pool and pgx.Tx have in common.
+type DBTX interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+// conn returns the transaction carried by ctx, or db when there is none.
+func conn(ctx context.Context, db *pgxpool.Pool) DBTX {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+type Transactor struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewTransactor(db *pgxpool.Pool, logger *zap.Logger) *Transactor {
+	return &Transactor{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithinTransaction nests as a savepoint when ctx already carries a transaction.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTransaction(ctx, t.db, t.logger, fn)
+}
+
+// withinTransaction is WithinTransaction for repositories that make several
+// writes which must land together, such as a change and its audit event.
+func withinTransaction(ctx context.Context, db *pgxpool.Pool, logger *zap.Logger, fn func(ctx context.Context) error) error {
+	tx, err := conn(ctx, db).Begin(ctx)
+	if err != nil {
+		info := "failed to begin transaction"
+		utils.Log(ctx, logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			utils.Log(ctx, logger).Error(utils.ErrDatabase.Error(), zap.String("error", "failed to roll back transaction"), zap.Error(rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		info := "failed to commit transaction"
+		utils.Log(ctx, logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/repository/pgtest"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var errAbort = errors.New("abort")
+
+func TestTransactorRollsBack(t *testing.T) {
+	db := pgtest.Open(t)
+	ctx := context.Background()
+	logger := zap.NewNop()
+	clock := utils.NewFakeClock(epoch)
+	transactor := NewTransactor(db, logger)
+	clients := NewClientRepo(db, logger)
+	scheduler := NewTransactionSchedulerRepo(db, clock, logger)
+
+	existing, existingBilling := createTestClient(t, db, "existing@example.com", model.Rupiah(100000))
+	before, err := clients.GetClientInfoRepo(ctx, existing.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audits := count(t, db, "audit_events")
+	clock.Advance(time.Hour)
+
+	added, addedBilling := newTestClient("added@example.com", model.Rupiah(50000), clock.Now())
+	err = transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := clients.CreateClientRepo(ctx, added, addedBilling); err != nil {
+			return err
+		}
+		if _, err := scheduler.UpdateBalance(ctx, existing.ClientID, model.Rupiah(-2000), model.ReasonHourlyCharge); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateTotalFee(ctx, existingBilling.BillingID, model.Rupiah(2000)); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateClientInfo(ctx, existing.ClientID); err != nil {
+			return err
+		}
+		if err := scheduler.UpdateBillingInfo(ctx, existingBilling.BillingID, 42); err != nil {
+			return err
+		}
+		if err := scheduler.SuspendClient(ctx, existing.ClientID, model.ReasonNegativeBalance); err != nil {
+			return err
+		}
+		if err := scheduler.InsertTransaction(ctx, &model.Transaction{
+			TransactionID: uuid.New(), ClientID: existing.ClientID, BillingID: existingBilling.BillingID,
+			Type: model.HourlyChargeTransaction, Amount: model.Rupiah(2000), TaxAmount: model.Rupiah(220),
+			BalanceAfter: model.Rupiah(97780), ExchangeRate: model.IdentityRate,
+			PeriodStart: epoch, PeriodEnd: clock.Now(), CreatedAt: clock.Now(),
+		}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err = %v, want %v", err, errAbort)
+	}
+
+	after, err := clients.GetClientInfoRepo(ctx, existing.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Balance != before.Balance || after.TotalFee != before.TotalFee || after.Uptime != before.Uptime ||
+		after.Suspended != before.Suspended || !after.ClientUpdated.Equal(before.ClientUpdated) {
+		t.Errorf("client changed by a rolled back transaction:\n got %+v\nwant %+v", after, before)
+	}
+	var carry int64
+	if err := db.QueryRow(ctx, `SELECT tax_carry FROM billings WHERE billing_id = $1`, existingBilling.BillingID).Scan(&carry); err != nil || carry != 0 {
+		t.Errorf("tax carry = %d (%v) after rollback", carry, err)
+	}
+	if _, err := clients.GetClientInfoRepo(ctx, added.ClientID); !errors.Is(err, utils.ErrNotFound) {
+		t.Errorf("client created in a rolled back transaction: %v", err)
+	}
+	if n := count(t, db, "billings"); n != 1 {
+		t.Errorf("%d billings, want only the existing one", n)
+	}
+	if n := count(t, db, "transactions"); n != 0 {
+		t.Errorf("%d transactions kept after rollback", n)
+	}
+	if n := count(t, db, "audit_events"); n != audits {
+		t.Errorf("%d audit events, want %d", n, audits)
+	}
+}
@@ -14,85 +14,158 @@ import (
 )
 
 type TransactionSchedulerRepoImpl interface {
-	GetActiveClient(ctx context.Context) ([]model.UpdateClient, error)
-	UpdateBalance(ctx context.Context, tx pgx.Tx, clientID uuid.UUID, newBalance int) error
-	UpdateTotalFee(ctx context.Context, tx pgx.Tx, billingID uuid.UUID, newFee int) error
-	UpdateClientInfo(ctx context.Context, tx pgx.Tx, clientID uuid.UUID) error
-	UpdateBillingInfo(ctx context.Context, tx pgx.Tx, billingID uuid.UUID, newUptime int) error
-	SuspendClient(ctx context.Context, tx pgx.Tx, clientID uuid.UUID) error
+	GetActiveClient(ctx context.Context, dueBefore time.Time) ([]model.UpdateClient, error)
+	ClaimClient(ctx context.Context, clientID uuid.UUID, dueBefore time.Time) (*model.UpdateClient, error)
+	UpdateBalance(ctx context.Context, clientID uuid.UUID, delta model.Money, reason string) (model.Money, error)
+	UpdateTotalFee(ctx context.Context, billingID uuid.UUID, fee model.Money) error
+	UpdateClientInfo(ctx context.Context, clientID uuid.UUID) error
+	UpdateBillingInfo(ctx context.Context, billingID uuid.UUID, taxCarry int64) error
+	SuspendClient(ctx context.Context, clientID uuid.UUID, reason string) error
+	InsertTransaction(ctx context.Context, transaction *model.Transaction) error
+	ChargeDueBatch(ctx context.Context, dueBefore, now time.Time, limit, taxRateBps int, rates map[model.Currency]model.Rate) ([]model.ChargeResult, error)
 }
 type TransactionSchedulerRepo struct {
 	logger *zap.Logger
 	db     *pgxpool.Pool
+	clock  utils.Clock
 }
 
-func NewTransactionSchedulerRepo(db *pgxpool.Pool, logger *zap.Logger) *TransactionSchedulerRepo {
+func NewTransactionSchedulerRepo(db *pgxpool.Pool, clock utils.Clock, logger *zap.Logger) *TransactionSchedulerRepo {
 	return &TransactionSchedulerRepo{
 		db:     db,
+		clock:  clock,
 		logger: logger,
 	}
 }
 
-func (hr *TransactionSchedulerRepo) GetActiveClient(ctx context.Context) ([]model.UpdateClient, error) {
-	rows, err := hr.db.Query(ctx, `
-    SELECT c.client_id, c.suspended, c.balance, c.updated_at, b.monthly_fee, b.cost_per_hour, b.total_fee, b.uptime, b.billing_id
+const updateClientColumns = `
+    c.client_id, c.suspended, c.tax_exempt, COALESCE(c.plan, ''), c.currency, c.balance, c.updated_at,
+    b.monthly_fee, b.cost_per_hour, b.total_fee, b.uptime, b.tax_carry, b.billing_id`
+
+func scanUpdateClient(row pgx.Row, client *model.UpdateClient) error {
+	err := row.Scan(
+		&client.ClientID,
+		&client.Suspended,
+		&client.TaxExempt,
+		&client.Plan,
+		&client.Currency,
+		&client.Balance,
+		&client.UpdatedAt,
+		&client.MonthlyFee,
+		&client.CostPerHour,
+		&client.TotalFee,
+		&client.Uptime,
+		&client.TaxCarry,
+		&client.BillingID,
+	)
+	inCurrency(client.Currency, &client.Balance, &client.MonthlyFee, &client.CostPerHour, &client.TotalFee)
+	return err
+}
+
+// GetActiveClient lists the active clients last charged at or before dueBefore.
+// It takes no locks; the charge itself claims each client with ClaimClient.
+func (hr *TransactionSchedulerRepo) GetActiveClient(ctx context.Context, dueBefore time.Time) ([]model.UpdateClient, error) {
+	rows, err := conn(ctx, hr.db).Query(ctx, `
+    SELECT`+updateClientColumns+`
     FROM clients c
     JOIN billings b ON c.client_id = b.client_id
-    WHERE c.suspended = false
-    FOR UPDATE
-    `)
+    WHERE c.suspended = false AND c.updated_at <= $1
+    `, dueBefore)
 	if err != nil {
 		info := "failed to get client info"
-		hr.logger.Error(utils.ErrBadRequest.Error(), zap.String("error", info), zap.Error(err))
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
 		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 	}
 	defer rows.Close()
 	var clients []model.UpdateClient
 	for rows.Next() {
 		var client model.UpdateClient
-		err := rows.Scan(
-			&client.ClientID,
-			&client.Suspended,
-			&client.Balance,
-			&client.UpdatedAt,
-			&client.MonthlyFee,
-			&client.CostPerHour,
-			&client.TotalFee,
-			&client.Uptime,
-			&client.BillingID,
-		)
-		if err != nil {
+		if err := scanUpdateClient(rows, &client); err != nil {
 			info := "failed while scanning client info"
-			hr.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
 			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 		}
 		clients = append(clients, client)
 	}
+	if err := rows.Err(); err != nil {
+		info := "failed while reading client info"
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
 	return clients, nil
 }
 
-func (hr *TransactionSchedulerRepo) UpdateBalance(ctx context.Context, tx pgx.Tx, clientID uuid.UUID, newBalance int) error {
-	_, err := tx.Exec(ctx, `
-		UPDATE clients SET balance = $1 WHERE client_id = $2
-	`, newBalance, clientID)
+// ClaimClient locks the client row for the rest of tx if the client is still
+// active and due. Rows locked by another transaction are skipped rather than
+// waited for, so concurrent schedulers never charge the same client-hour
+// twice. ErrNotFound means there is nothing to charge.
+func (hr *TransactionSchedulerRepo) ClaimClient(ctx context.Context, clientID uuid.UUID, dueBefore time.Time) (*model.UpdateClient, error) {
+	var client model.UpdateClient
+	err := scanUpdateClient(conn(ctx, hr.db).QueryRow(ctx, `
+    SELECT`+updateClientColumns+`
+    FROM clients c
+    JOIN billings b ON c.client_id = b.client_id
+    WHERE c.client_id = $1 AND c.suspended = false AND c.updated_at <= $2
+    FOR UPDATE OF c SKIP LOCKED
+    `, clientID, dueBefore), &client)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("client not due or claimed elsewhere: %w", utils.ErrNotFound)
+	} else if err != nil {
+		info := "failed to claim client"
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
+			zap.String("error", info),
+			zap.String("client_id", clientID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return &client, nil
+}
+
+// UpdateBalance adds delta (negative for a charge) to the stored balance and
+// returns the result, so concurrent top-ups and adjustments are never
+// overwritten by a stale value. The change is recorded with reason in the
+// audit log; call it within a transaction so the two land together.
+func (hr *TransactionSchedulerRepo) UpdateBalance(ctx context.Context, clientID uuid.UUID, delta model.Money, reason string) (model.Money, error) {
+	balance := model.Money{Currency: delta.Currency}
+	err := conn(ctx, hr.db).QueryRow(ctx, `
+		UPDATE clients SET balance = balance + $1 WHERE client_id = $2 AND currency = $3 RETURNING balance
+	`, delta, clientID, string(delta.Currency)).Scan(&balance)
 	if err != nil {
 		info := "failed to update balance"
-		hr.logger.Error(utils.ErrDatabase.Error(),
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
 			zap.String("error", info),
 			zap.String("client_id", clientID.String()),
 			zap.Error(err))
-		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		return model.Money{}, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 	}
-	return nil
+	before, err := balance.Sub(delta)
+	if err != nil {
+		return model.Money{}, err
+	}
+	err = recordAudit(ctx, conn(ctx, hr.db), hr.logger, &model.AuditEvent{
+		OccurredAt: hr.clock.Now(),
+		Action:     model.AuditBalanceChanged,
+		EntityType: "client",
+		EntityID:   clientID.String(),
+		ClientID:   &clientID,
+		Before:     map[string]model.Money{"balance": before},
+		After:      map[string]model.Money{"balance": balance},
+		Reason:     reason,
+	})
+	if err != nil {
+		return model.Money{}, err
+	}
+	return balance, nil
 }
 
-func (hr *TransactionSchedulerRepo) UpdateTotalFee(ctx context.Context, tx pgx.Tx, billingID uuid.UUID, newFee int) error {
-	_, err := tx.Exec(ctx, `
-		UPDATE billings SET total_fee = $1 WHERE billing_id = $2
-	`, newFee, billingID)
+// UpdateTotalFee adds fee to the billing's running total.
+func (hr *TransactionSchedulerRepo) UpdateTotalFee(ctx context.Context, billingID uuid.UUID, fee model.Money) error {
+	_, err := conn(ctx, hr.db).Exec(ctx, `
+		UPDATE billings SET total_fee = total_fee + $1 WHERE billing_id = $2
+	`, fee, billingID)
 	if err != nil {
 		info := "failed to update total fee"
-		hr.logger.Error(utils.ErrDatabase.Error(),
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
 			zap.String("error", info),
 			zap.String("billing_id", billingID.String()),
 			zap.Error(err))
@@ -101,13 +174,13 @@ func (hr *TransactionSchedulerRepo) UpdateTotalFee(ctx context.Context, tx pgx.T
 	return nil
 }
 
-func (hr *TransactionSchedulerRepo) UpdateClientInfo(ctx context.Context, tx pgx.Tx, clientID uuid.UUID) error {
-	_, err := tx.Exec(ctx, `
+func (hr *TransactionSchedulerRepo) UpdateClientInfo(ctx context.Context, clientID uuid.UUID) error {
+	_, err := conn(ctx, hr.db).Exec(ctx, `
 		UPDATE clients SET updated_at = $1 WHERE client_id = $2
-	`, time.Now(), clientID)
+	`, hr.clock.Now(), clientID)
 	if err != nil {
 		info := "failed to update client info"
-		hr.logger.Error(utils.ErrDatabase.Error(),
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
 			zap.String("error", info),
 			zap.String("client_id", clientID.String()),
 			zap.Error(err))
@@ -116,13 +189,15 @@ func (hr *TransactionSchedulerRepo) UpdateClientInfo(ctx context.Context, tx pgx
 	return nil
 }
 
-func (hr *TransactionSchedulerRepo) UpdateBillingInfo(ctx context.Context, tx pgx.Tx, billingID uuid.UUID, newUptime int) error {
-	_, err := tx.Exec(ctx, `
-		UPDATE billings SET uptime = $1, updated_at = $2 WHERE billing_id = $3
-	`, newUptime, time.Now(), billingID)
+// UpdateBillingInfo records one more hour of uptime and the tax carried into
+// the next charge.
+func (hr *TransactionSchedulerRepo) UpdateBillingInfo(ctx context.Context, billingID uuid.UUID, taxCarry int64) error {
+	_, err := conn(ctx, hr.db).Exec(ctx, `
+		UPDATE billings SET uptime = uptime + 1, tax_carry = $1, updated_at = $2 WHERE billing_id = $3
+	`, taxCarry, hr.clock.Now(), billingID)
 	if err != nil {
 		info := "failed to update billing info"
-		hr.logger.Error(utils.ErrDatabase.Error(),
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
 			zap.String("error", info),
 			zap.String("billing_id", billingID.String()),
 			zap.Error(err))
@@ -131,15 +206,164 @@ func (hr *TransactionSchedulerRepo) UpdateBillingInfo(ctx context.Context, tx pg
 	return nil
 }
 
-func (hr *TransactionSchedulerRepo) SuspendClient(ctx context.Context, tx pgx.Tx, clientID uuid.UUID) error {
-	_, err := tx.Exec(ctx, `UPDATE clients SET suspended = true WHERE client_id = $1`, clientID)
+// SuspendClient suspends an active client and records why in the audit
+// log. Suspending a suspended client changes nothing and records nothing.
+func (hr *TransactionSchedulerRepo) SuspendClient(ctx context.Context, clientID uuid.UUID, reason string) error {
+	tag, err := conn(ctx, hr.db).Exec(ctx, `UPDATE clients SET suspended = true WHERE client_id = $1 AND suspended = false`, clientID)
 	if err != nil {
 		info := "failed to suspend clients"
-		hr.logger.Error(utils.ErrDatabase.Error(),
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
 			zap.String("error", info),
 			zap.String("billing_id", clientID.String()),
 			zap.Error(err))
 		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
 	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	return recordAudit(ctx, conn(ctx, hr.db), hr.logger, &model.AuditEvent{
+		OccurredAt: hr.clock.Now(),
+		Action:     model.AuditClientSuspended,
+		EntityType: "client",
+		EntityID:   clientID.String(),
+		ClientID:   &clientID,
+		Before:     map[string]bool{"suspended": false},
+		After:      map[string]bool{"suspended": true},
+		Reason:     reason,
+	})
+}
+
+func (hr *TransactionSchedulerRepo) InsertTransaction(ctx context.Context, transaction *model.Transaction) error {
+	_, err := conn(ctx, hr.db).Exec(ctx, `
+		INSERT INTO transactions
+		(transaction_id, client_id, billing_id, type, currency, amount, tax_amount, balance_after, exchange_rate_micros,
+		period_start, period_end, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, transaction.TransactionID, transaction.ClientID, transaction.BillingID, transaction.Type, string(transaction.Amount.Currency),
+		transaction.Amount, transaction.TaxAmount, transaction.BalanceAfter, int64(transaction.ExchangeRate),
+		transaction.PeriodStart, transaction.PeriodEnd, transaction.CreatedAt)
+	if err != nil {
+		info := "failed to record transaction"
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(),
+			zap.String("error", info),
+			zap.String("client_id", transaction.ClientID.String()),
+			zap.Error(err))
+		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
 	return nil
 }
+
+// ChargeDueBatch charges one hour to up to limit due clients with a single
+// set-based statement: it claims the clients (skipping rows locked by other
+// schedulers), applies balance, total fee, uptime and suspension, and records
+// a transaction for each. Tax is worked out with each billing's tax carry
+// as in model.TaxRule.Calculate; tax exempt clients are charged no tax. Clients
+// whose currency has no entry in rates are left for a later run.
+func (hr *TransactionSchedulerRepo) ChargeDueBatch(ctx context.Context, dueBefore, now time.Time, limit, taxRateBps int, rates map[model.Currency]model.Rate) ([]model.ChargeResult, error) {
+	currencies := make([]string, 0, len(rates))
+	micros := make([]int64, 0, len(rates))
+	for currency, rate := range rates {
+		currencies = append(currencies, string(currency))
+		micros = append(micros, int64(rate))
+	}
+	// Balance changes and suspensions are recorded in the audit log by the
+	// same statement, as UpdateBalance and SuspendClient do one by one.
+	actor := utils.ActorFrom(ctx)
+	rows, err := conn(ctx, hr.db).Query(ctx, `
+	WITH fx AS (
+	  SELECT * FROM unnest($6::TEXT[], $7::BIGINT[]) AS fx(currency, rate_micros)
+	),
+	due AS (
+	  SELECT c.client_id, c.updated_at, c.tax_exempt, COALESCE(c.plan, '') AS plan, c.currency, fx.rate_micros, b.billing_id, b.cost_per_hour, b.monthly_fee,
+	    b.tax_carry AS carry_before
+	  FROM clients c
+	  JOIN billings b ON b.client_id = c.client_id
+	  JOIN fx ON fx.currency = c.currency
+	  WHERE c.suspended = false AND c.updated_at <= $1
+	  ORDER BY c.updated_at
+	  LIMIT $2
+	  FOR UPDATE OF c SKIP LOCKED
+	),
+	charge AS (
+	  SELECT due.*,
+	    CASE WHEN due.tax_exempt THEN 0 ELSE (due.cost_per_hour * $3 + due.carry_before) / 10000 END AS tax_amount,
+	    CASE WHEN due.tax_exempt THEN due.carry_before ELSE (due.cost_per_hour * $3 + due.carry_before) % 10000 END AS tax_carry
+	  FROM due
+	),
+	charged_clients AS (
+	  UPDATE clients c
+	  SET balance = c.balance - ch.cost_per_hour - ch.tax_amount,
+	      suspended = c.balance - ch.cost_per_hour - ch.tax_amount < 0,
+	      updated_at = $4
+	  FROM charge ch
+	  WHERE c.client_id = ch.client_id
+	  RETURNING c.client_id, c.balance, c.suspended
+	),
+	charged_billings AS (
+	  UPDATE billings b
+	  SET total_fee = b.total_fee + ch.cost_per_hour, uptime = b.uptime + 1, tax_carry = ch.tax_carry, updated_at = $4
+	  FROM charge ch
+	  WHERE b.billing_id = ch.billing_id
+	),
+	recorded AS (
+	  INSERT INTO transactions
+	  (transaction_id, client_id, billing_id, type, currency, amount, tax_amount, balance_after, exchange_rate_micros,
+	   period_start, period_end, created_at)
+	  SELECT gen_random_uuid(), ch.client_id, ch.billing_id, $5, ch.currency, ch.cost_per_hour, ch.tax_amount,
+	    cc.balance, ch.rate_micros, ch.updated_at, $4, $4
+	  FROM charge ch
+	  JOIN charged_clients cc ON cc.client_id = ch.client_id
+	),
+	audited_balances AS (
+	  INSERT INTO audit_events
+	  (event_id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, client_id, before, after, reason, request_id)
+	  SELECT gen_random_uuid(), $4, $8::TEXT, $9::TEXT, $11::TEXT, 'client', ch.client_id::TEXT, ch.client_id,
+	    jsonb_build_object('balance', jsonb_build_object('amount', cc.balance + ch.cost_per_hour + ch.tax_amount, 'currency', ch.currency)),
+	    jsonb_build_object('balance', jsonb_build_object('amount', cc.balance, 'currency', ch.currency)),
+	    $12::TEXT, $10::TEXT
+	  FROM charge ch
+	  JOIN charged_clients cc ON cc.client_id = ch.client_id
+	),
+	audited_suspensions AS (
+	  INSERT INTO audit_events
+	  (event_id, occurred_at, actor_type, actor_id, action, entity_type, entity_id, client_id, before, after, reason, request_id)
+	  SELECT gen_random_uuid(), $4, $8::TEXT, $9::TEXT, $13::TEXT, 'client', cc.client_id::TEXT, cc.client_id,
+	    '{"suspended": false}'::JSONB, '{"suspended": true}'::JSONB, $14::TEXT, $10::TEXT
+	  FROM charged_clients cc
+	  WHERE cc.suspended
+	)
+	SELECT ch.client_id, ch.billing_id, ch.plan, ch.currency, ch.cost_per_hour, ch.tax_amount, cc.balance, ch.monthly_fee,
+	  ch.rate_micros, cc.suspended
+	FROM charge ch
+	JOIN charged_clients cc ON cc.client_id = ch.client_id
+	`, dueBefore, limit, taxRateBps, now, model.HourlyChargeTransaction, currencies, micros,
+		actor.Type, actor.ID, utils.RequestID(ctx),
+		model.AuditBalanceChanged, model.ReasonHourlyCharge, model.AuditClientSuspended, model.ReasonNegativeBalance)
+	if err != nil {
+		info := "failed to charge batch"
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	defer rows.Close()
+
+	var results []model.ChargeResult
+	for rows.Next() {
+		var result model.ChargeResult
+		var currency model.Currency
+		err := rows.Scan(&result.ClientID, &result.BillingID, &result.Plan, &currency, &result.Amount, &result.TaxAmount,
+			&result.BalanceAfter, &result.MonthlyFee, &result.ExchangeRate, &result.Suspended)
+		if err != nil {
+			info := "failed while scanning batch charges"
+			utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+			return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		}
+		inCurrency(currency, &result.Amount, &result.TaxAmount, &result.BalanceAfter, &result.MonthlyFee)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		info := "failed to charge batch"
+		utils.Log(ctx, hr.logger).Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
+		return nil, fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+	}
+	return results, nil
+}
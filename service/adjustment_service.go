@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type AdjustmentServiceImpl interface {
+	CreateAdjustmentService(ctx context.Context, clientID uuid.UUID, input *req.NewAdjustment) (*model.Adjustment, error)
+	ReverseAdjustmentService(ctx context.Context, clientID, transactionID uuid.UUID, input *req.AdjustmentReversal) (*model.Adjustment, error)
+	ListAdjustmentsService(ctx context.Context, clientID uuid.UUID) ([]model.Adjustment, error)
+}
+
+type AdjustmentService struct {
+	transactor repository.TransactorImpl
+	repo       repository.AdjustmentRepoImpl
+	balances   repository.TransactionSchedulerRepoImpl
+	fx         ExchangeRateServiceImpl
+	clock      utils.Clock
+	logger     *zap.Logger
+}
+
+func NewAdjustmentService(transactor repository.TransactorImpl, repo repository.AdjustmentRepoImpl, balances repository.TransactionSchedulerRepoImpl, fx ExchangeRateServiceImpl, clock utils.Clock, logger *zap.Logger) *AdjustmentService {
+	return &AdjustmentService{
+		transactor: transactor,
+		repo:       repo,
+		balances:   balances,
+		fx:         fx,
+		clock:      clock,
+		logger:     logger,
+	}
+}
+
+// CreateAdjustmentService adds input.Amount to the client's balance and
+// records it as an adjustment transaction, all in one transaction.
+func (as *AdjustmentService) CreateAdjustmentService(ctx context.Context, clientID uuid.UUID, input *req.NewAdjustment) (*model.Adjustment, error) {
+	ctx, span := tracing.Start(ctx, "AdjustmentService.CreateAdjustmentService")
+	defer span.End()
+	var adjustment *model.Adjustment
+	err := as.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		client, err := as.repo.LockAdjustmentClient(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		if input.Currency != "" && input.Currency != client.Currency {
+			info := fmt.Sprintf("client is billed in %s, not %s", client.Currency, input.Currency)
+			utils.Log(ctx, as.logger).Warn(utils.ErrBadRequest.Error(), zap.String("warn", info), zap.String("client_id", clientID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrBadRequest)
+		}
+		adjustment, err = as.apply(ctx, client, &model.Adjustment{
+			Amount:     model.NewMoney(input.Amount, client.Currency),
+			ReasonCode: input.ReasonCode,
+			Note:       input.Note,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+// ReverseAdjustmentService undoes an adjustment with a new one of the
+// opposite amount. The original stays in the history; each adjustment can
+// be reversed once, and reversals themselves cannot be.
+func (as *AdjustmentService) ReverseAdjustmentService(ctx context.Context, clientID, transactionID uuid.UUID, input *req.AdjustmentReversal) (*model.Adjustment, error) {
+	ctx, span := tracing.Start(ctx, "AdjustmentService.ReverseAdjustmentService")
+	defer span.End()
+	var reversal *model.Adjustment
+	err := as.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		client, err := as.repo.LockAdjustmentClient(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		original, err := as.repo.GetAdjustment(ctx, clientID, transactionID)
+		if err != nil {
+			return err
+		}
+		if original.Reverses != nil {
+			info := "a reversal cannot be reversed"
+			utils.Log(ctx, as.logger).Warn(utils.ErrBadRequest.Error(), zap.String("warn", info), zap.String("transaction_id", transactionID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrBadRequest)
+		}
+		if original.ReversedBy != nil {
+			info := "adjustment is already reversed"
+			utils.Log(ctx, as.logger).Warn(utils.ErrExists.Error(), zap.String("warn", info), zap.String("transaction_id", transactionID.String()))
+			return fmt.Errorf("%s: %w", info, utils.ErrExists)
+		}
+		amount, err := original.Amount.Neg()
+		if err != nil {
+			return err
+		}
+		reversal, err = as.apply(ctx, client, &model.Adjustment{
+			Amount:     amount,
+			ReasonCode: model.AdjustmentReversal,
+			Note:       input.Note,
+			Reverses:   &original.TransactionID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+func (as *AdjustmentService) ListAdjustmentsService(ctx context.Context, clientID uuid.UUID) ([]model.Adjustment, error) {
+	ctx, span := tracing.Start(ctx, "AdjustmentService.ListAdjustmentsService")
+	defer span.End()
+	return as.repo.ListAdjustments(ctx, clientID)
+}
+
+// apply changes the balance of the locked client by adjustment.Amount and
+// records the transaction and the adjustment. It must run within a
+// transaction.
+func (as *AdjustmentService) apply(ctx context.Context, client *model.UpdateClient, adjustment *model.Adjustment) (*model.Adjustment, error) {
+	now := as.clock.Now()
+	rate, err := as.fx.RateAt(ctx, client.Currency, now)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := as.balances.UpdateBalance(ctx, client.ClientID, adjustment.Amount, adjustment.ReasonCode)
+	if err != nil {
+		return nil, err
+	}
+	charge, err := adjustment.Amount.Neg()
+	if err != nil {
+		return nil, err
+	}
+	adjustment.TransactionID = uuid.New()
+	adjustment.ClientID = client.ClientID
+	adjustment.BalanceAfter = balance
+	adjustment.CreatedBy = utils.ActorFrom(ctx)
+	adjustment.CreatedAt = now
+	err = as.balances.InsertTransaction(ctx, &model.Transaction{
+		TransactionID: adjustment.TransactionID,
+		ClientID:      client.ClientID,
+		BillingID:     client.BillingID,
+		Type:          model.AdjustmentTransaction,
+		Amount:        charge,
+		TaxAmount:     model.NewMoney(0, client.Currency),
+		BalanceAfter:  balance,
+		ExchangeRate:  rate,
+		PeriodStart:   now,
+		PeriodEnd:     now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := as.repo.InsertAdjustment(ctx, adjustment); err != nil {
+		return nil, err
+	}
+	utils.Log(ctx, as.logger).Info("Balance adjusted", zap.String("client_id", client.ClientID.String()),
+		zap.String("transaction_id", adjustment.TransactionID.String()), zap.String("amount", adjustment.Amount.String()),
+		zap.String("reason_code", adjustment.ReasonCode))
+	return adjustment, nil
+}
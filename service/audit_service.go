@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"go.uber.org/zap"
+)
+
+type AuditServiceImpl interface {
+	ListAuditEventsService(ctx context.Context, filter *req.AuditFilter) (*res.AuditList, error)
+}
+
+type AuditService struct {
+	repo   repository.AuditRepoImpl
+	logger *zap.Logger
+}
+
+func NewAuditService(repo repository.AuditRepoImpl, logger *zap.Logger) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// ListAuditEventsService returns one page of audit events matching filter,
+// newest first.
+func (as *AuditService) ListAuditEventsService(ctx context.Context, filter *req.AuditFilter) (*res.AuditList, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.ListAuditEventsService")
+	defer span.End()
+	return as.repo.ListAuditEvents(ctx, filter)
+}
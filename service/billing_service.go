@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+// exportFlushEvery controls how many rows are buffered before they are
+// pushed to the underlying writer.
+const exportFlushEvery = 500
+
+var billingHistoryCSVHeader = []string{
+	"transaction_id", "client_id", "email", "billing_id", "type",
+	"period_start", "period_end", "amount", "tax_amount", "balance_after", "currency", "exchange_rate", "total_idr", "created_at",
+}
+
+type BillingServiceImpl interface {
+	ExportBillingHistoryService(ctx context.Context, export *req.BillingExport, w io.Writer) error
+}
+
+type BillingService struct {
+	repo   repository.BillingRepoImpl
+	logger *zap.Logger
+}
+
+func NewBillingService(repo repository.BillingRepoImpl, logger *zap.Logger) *BillingService {
+	return &BillingService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// ExportBillingHistoryService streams the billing history of export's date
+// range to w as CSV or NDJSON. If w has a Flush method (http.ResponseWriter
+// does), it is flushed periodically so large exports start downloading
+// right away.
+func (bs *BillingService) ExportBillingHistoryService(ctx context.Context, export *req.BillingExport, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "BillingService.ExportBillingHistoryService")
+	defer span.End()
+	flush := func() {}
+	if f, ok := w.(interface{ Flush() }); ok {
+		flush = f.Flush
+	}
+
+	var write func(*res.BillingHistory) error
+	var done func() error
+	switch export.Format {
+	case req.ExportNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(h *res.BillingHistory) error { return enc.Encode(h) }
+		done = func() error { return nil }
+	default:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(billingHistoryCSVHeader); err != nil {
+			return fmt.Errorf("failed to write csv header: %w", err)
+		}
+		write = func(h *res.BillingHistory) error {
+			return cw.Write([]string{
+				h.TransactionID.String(), h.ClientID.String(), h.Email, h.BillingID.String(), h.Type,
+				h.PeriodStart.Format(time.RFC3339), h.PeriodEnd.Format(time.RFC3339),
+				strconv.FormatInt(h.Amount.Amount, 10), strconv.FormatInt(h.TaxAmount.Amount, 10), strconv.FormatInt(h.BalanceAfter.Amount, 10),
+				string(h.Amount.Currency), h.ExchangeRate.String(), strconv.FormatInt(h.TotalIDR.Amount, 10), h.CreatedAt.Format(time.RFC3339),
+			})
+		}
+		done = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	count := 0
+	err := bs.repo.StreamBillingHistory(ctx, export.From, export.To, func(h *res.BillingHistory) error {
+		// Converted at the rate snapshotted when the charge was made.
+		total, err := h.Amount.Add(h.TaxAmount)
+		if err != nil {
+			return err
+		}
+		if h.TotalIDR, err = h.ExchangeRate.Convert(total); err != nil {
+			return err
+		}
+		if err := write(h); err != nil {
+			return fmt.Errorf("failed to write billing history: %w", err)
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			if err := done(); err != nil {
+				return fmt.Errorf("failed to write billing history: %w", err)
+			}
+			flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = done()
+	}
+	if err != nil {
+		utils.Log(ctx, bs.logger).Error(utils.ErrInternal.Error(), zap.String("error", "billing export aborted"),
+			zap.Int("rows", count), zap.Error(err))
+		return err
+	}
+	flush()
+	utils.Log(ctx, bs.logger).Info("billing history exported", zap.String("format", export.Format),
+		zap.Time("from", export.From), zap.Time("to", export.To), zap.Int("rows", count))
+	return nil
+}
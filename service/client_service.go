@@ -3,12 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
-	"time"
 
+	"github.com/bagasadiii/maxcloud_vps/metrics"
 	"github.com/bagasadiii/maxcloud_vps/model"
 	"github.com/bagasadiii/maxcloud_vps/model/req"
 	"github.com/bagasadiii/maxcloud_vps/model/res"
 	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
 	"github.com/bagasadiii/maxcloud_vps/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -17,38 +18,70 @@ import (
 type ClientServiceImpl interface {
 	CreateClientService(ctx context.Context, req *req.NewClient) error
 	GetClientInfoService(ctx context.Context, clientID uuid.UUID) (*res.ClientInfo, error)
+	ListClientsService(ctx context.Context, filter *req.ClientFilter) (*res.ClientList, error)
+	UpdateClientTaxService(ctx context.Context, clientID uuid.UUID, tax *req.ClientTax) error
 }
 type ClientService struct {
 	repo   repository.ClientRepoImpl
+	clock  utils.Clock
 	logger *zap.Logger
 }
 
-func NewClientService(repo repository.ClientRepoImpl, logger *zap.Logger) *ClientService {
+func NewClientService(repo repository.ClientRepoImpl, clock utils.Clock, logger *zap.Logger) *ClientService {
 	return &ClientService{
 		repo:   repo,
+		clock:  clock,
 		logger: logger,
 	}
 }
 
 func (cs *ClientService) CreateClientService(ctx context.Context, req *req.NewClient) error {
-	billing, err := selectBilling(req.Plan)
+	ctx, span := tracing.Start(ctx, "ClientService.CreateClientService")
+	defer span.End()
+	prices, ok := model.Prices[req.Currency]
+	if !ok {
+		info := fmt.Sprintf("currency '%s' is not supported", req.Currency)
+		utils.Log(ctx, cs.logger).Error(utils.ErrBadRequest.Error(), zap.String("error", info))
+		return fmt.Errorf("%s: %w", info, utils.ErrBadRequest)
+	}
+	billing, err := selectBilling(req.Plan, prices)
+	if err != nil {
+		utils.Log(ctx, cs.logger).Error(utils.ErrBadRequest.Error(), zap.Error(err))
+		return fmt.Errorf("%v: %w", err, utils.ErrBadRequest)
+	}
+	monthlyFee, err := billing.CalculateMonthlyFee(prices)
+	if err != nil {
+		return err
+	}
+	costPerHour, err := billing.CalculateCostPerHour(prices)
+	if err != nil {
+		return err
+	}
+	remainingBalance, err := model.NewMoney(req.Balance, req.Currency).Sub(billing.DownPayment)
 	if err != nil {
-		cs.logger.Error(utils.ErrBadRequest.Error(), zap.Error(err))
-		return fmt.Errorf("%v: %w",err, utils.ErrBadRequest)
+		utils.Log(ctx, cs.logger).Warn(utils.ErrOverflow.Error(), zap.String("warn", "balance out of range"), zap.Error(err))
+		return err
 	}
-	remainingBalance := req.Balance - billing.DownPayment
-	if remainingBalance < billing.CalculateMonthlyFee() {
-		info := fmt.Sprintf("remaining balance: %d, Monthly fee: %d", remainingBalance, billing.CalculateMonthlyFee())
-		cs.logger.Error(utils.ErrBadRequest.Error(), zap.String("insufficient balance", info))
+	short, err := remainingBalance.Less(monthlyFee)
+	if err != nil {
+		return err
+	}
+	if short {
+		info := fmt.Sprintf("remaining balance: %d, Monthly fee: %d", remainingBalance.Amount, monthlyFee.Amount)
+		utils.Log(ctx, cs.logger).Error(utils.ErrBadRequest.Error(), zap.String("insufficient balance", info))
 		return fmt.Errorf("insufficient fund: %s: %w", info, utils.ErrBadRequest)
 	}
+	now := cs.clock.Now()
 	client := &model.Client{
 		ClientID:  uuid.New(),
 		Email:     req.Email,
+		Plan:      req.Plan,
+		TaxID:     req.TaxID,
+		Currency:  req.Currency,
 		Suspended: false,
 		Balance:   remainingBalance,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 	clientBilling := &model.Billing{
 		BillingID:   uuid.New(),
@@ -56,43 +89,52 @@ func (cs *ClientService) CreateClientService(ctx context.Context, req *req.NewCl
 		CPU:         billing.CPU,
 		RAM:         billing.RAM,
 		Storage:     billing.Storage,
-		MonthlyFee:  billing.CalculateMonthlyFee(),
-		CostPerHour: billing.CalculateCostPerHour(),
-		TotalFee:    0,
+		DownPayment: billing.DownPayment,
+		MonthlyFee:  monthlyFee,
+		CostPerHour: costPerHour,
+		TotalFee:    model.NewMoney(0, req.Currency),
 		Uptime:      0,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	return cs.repo.CreateClientRepo(ctx, client, clientBilling)
+	if err := cs.repo.CreateClientRepo(ctx, client, clientBilling); err != nil {
+		return err
+	}
+	metrics.Registered(client.Plan, client.Currency)
+	return nil
 }
 
 func (cs *ClientService) GetClientInfoService(ctx context.Context, clientID uuid.UUID) (*res.ClientInfo, error) {
+	ctx, span := tracing.Start(ctx, "ClientService.GetClientInfoService")
+	defer span.End()
 	return cs.repo.GetClientInfoRepo(ctx, clientID)
 }
 
-func selectBilling(plan string) (*model.Billing, error) {
+func (cs *ClientService) ListClientsService(ctx context.Context, filter *req.ClientFilter) (*res.ClientList, error) {
+	ctx, span := tracing.Start(ctx, "ClientService.ListClientsService")
+	defer span.End()
+	return cs.repo.ListClientsRepo(ctx, filter)
+}
+
+func (cs *ClientService) UpdateClientTaxService(ctx context.Context, clientID uuid.UUID, tax *req.ClientTax) error {
+	ctx, span := tracing.Start(ctx, "ClientService.UpdateClientTaxService")
+	defer span.End()
+	return cs.repo.UpdateClientTaxRepo(ctx, clientID, tax)
+}
+
+func selectBilling(plan string, prices model.PriceList) (*model.Billing, error) {
+	var billing model.Billing
 	switch plan {
 	case model.BasicBilling:
-		return &model.Billing{
-			CPU:         model.Basic.CPU,
-			RAM:         model.Basic.RAM,
-			Storage:     model.Basic.Storage,
-			DownPayment: model.Basic.DownPayment,
-		}, nil
+		billing = model.Basic
 	case model.NormalBilling:
-		return &model.Billing{
-			CPU:         model.Normal.CPU,
-			RAM:         model.Normal.RAM,
-			Storage:     model.Normal.Storage,
-			DownPayment: model.Normal.DownPayment,
-		}, nil
+		billing = model.Normal
 	case model.PremiumBilling:
-		return &model.Billing{
-			CPU:         model.Premium.CPU,
-			RAM:         model.Premium.RAM,
-			Storage:     model.Premium.Storage,
-			DownPayment: model.Premium.DownPayment,
-		}, nil
+		billing = model.Premium
 	default:
 		return nil, fmt.Errorf("plan '%s' is not recognized", plan)
 	}
+	billing.DownPayment = model.NewMoney(prices.DownPayment[plan], prices.Currency)
+	return &billing, nil
 }
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+)
+
+func TestCreateClientService(t *testing.T) {
+	env := newTestEnv(t)
+	id := env.register(t, "Basic@Example.com", model.BasicBilling, model.Rupiah(1500000))
+
+	info := env.info(t, id)
+	if info.Email != "basic@example.com" || info.Plan != model.BasicBilling || info.Currency != model.IDR || info.Suspended {
+		t.Errorf("client = %s %s %s suspended=%t", info.Email, info.Plan, info.Currency, info.Suspended)
+	}
+	// 1 CPU, 1 GB RAM and 8 GB storage at 200 each per hour, for 720 hours.
+	checks := []struct {
+		name      string
+		got, want model.Money
+	}{
+		{"balance", info.Balance, model.Rupiah(1500000 - 15000)},
+		{"down payment", info.DownPayment, model.Rupiah(15000)},
+		{"cost per hour", info.CostPerHour, model.Rupiah(2000)},
+		{"monthly fee", info.MonthlyFee, model.Rupiah(1440000)},
+		{"total fee", info.TotalFee, model.Rupiah(0)},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %s, want %s", c.name, c.got, c.want)
+		}
+	}
+	if info.CPU != 1 || info.RAM != 1024 || info.Storage != 8 || info.Uptime != 0 {
+		t.Errorf("billing = %d CPU, %d RAM, %d storage, uptime %d", info.CPU, info.RAM, info.Storage, info.Uptime)
+	}
+	if !info.ClientCreated.Equal(epoch) || !info.BillingCreated.Equal(epoch) {
+		t.Errorf("created at %s and %s, want %s", info.ClientCreated, info.BillingCreated, epoch)
+	}
+}
+
+func TestCreateClientServiceRejects(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "taken@example.com", model.BasicBilling, model.Rupiah(1500000))
+
+	tests := []struct {
+		name  string
+		input req.NewClient
+		want  error
+	}{
+		// One rupiah short of the down payment plus a month of basic.
+		{"insufficient", req.NewClient{Email: "poor@example.com", Balance: 1454999, Currency: model.IDR, Plan: model.BasicBilling}, utils.ErrBadRequest},
+		{"unknown plan", req.NewClient{Email: "odd@example.com", Balance: 5000000, Currency: model.IDR, Plan: "gold"}, utils.ErrBadRequest},
+		{"unsupported currency", req.NewClient{Email: "eur@example.com", Balance: 5000000, Currency: "EUR", Plan: model.BasicBilling}, utils.ErrBadRequest},
+		{"duplicate email", req.NewClient{Email: "taken@example.com", Balance: 1500000, Currency: model.IDR, Plan: model.BasicBilling}, utils.ErrExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.clients.CreateClientService(context.Background(), &tt.input)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	list, err := env.clients.ListClientsService(context.Background(), &req.ClientFilter{SortBy: req.SortCreatedAt, Limit: req.MaxListLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Clients) != 1 {
+		t.Errorf("%d clients stored, want only the first one", len(list.Clients))
+	}
+}
+
+func TestCreateClientServiceExactMinimum(t *testing.T) {
+	env := newTestEnv(t)
+	id := env.register(t, "exact@example.com", model.BasicBilling, model.Rupiah(1455000))
+	if got := env.info(t, id).Balance; got != model.Rupiah(1440000) {
+		t.Errorf("balance = %s, want exactly one monthly fee", got)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/google/uuid"
+)
+
+const (
+	concurrentHours        = 48
+	concurrentAdjusters    = 4
+	adjustmentsPerAdjuster = 25
+)
+
+// adjustmentAmount alternates credits and smaller debits, so the expected
+// balance depends on every one of them landing.
+func adjustmentAmount(adjuster, i int) int64 {
+	amount := int64(1000 * (adjuster + 1))
+	if i%2 == 1 {
+		amount = -amount / 2
+	}
+	return amount
+}
+
+// chargeHourly advances the clock one interval at a time and charges the
+// due clients each hour, returning how many charges were made.
+func chargeHourly(t *testing.T, env *testEnv, hours int) int {
+	charged := 0
+	for i := 0; i < hours; i++ {
+		env.clock.Advance(env.billing.Interval)
+		n, err := env.scheduler.ChargeDueClients(context.Background())
+		if err != nil {
+			t.Fatalf("charge in hour %d: %v", i+1, err)
+		}
+		charged += n
+	}
+	return charged
+}
+
+// runConcurrently charges env's clients every hour while adjusters change a
+// balance with adjust, and returns the charges made and the sum of the adjustments.
+func runConcurrently(t *testing.T, env *testEnv, adjust func(ctx context.Context, amount int64) error) (int, int64) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var adjusted atomic.Int64
+	for a := 0; a < concurrentAdjusters; a++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adjustmentsPerAdjuster; i++ {
+				amount := adjustmentAmount(a, i)
+				if err := adjust(ctx, amount); err != nil {
+					t.Errorf("adjust by %d: %v", amount, err)
+					return
+				}
+				adjusted.Add(amount)
+			}
+		}()
+	}
+	charged := chargeHourly(t, env, concurrentHours)
+	wg.Wait()
+	return charged, adjusted.Load()
+}
+
+func checkConcurrentBalance(t *testing.T, env *testEnv, id uuid.UUID, start model.Money, charged int, adjusted int64) {
+	t.Helper()
+	info := env.info(t, id)
+	want := model.Rupiah(start.Amount + adjusted - int64(charged)*basicHourlyCharge)
+	if info.Balance != want {
+		t.Errorf("balance = %s, want %s from %d charges and %d in adjustments", info.Balance, want, charged, adjusted)
+	}
+	if info.Uptime != charged || info.TotalFee != model.Rupiah(int64(charged)*2000) {
+		t.Errorf("uptime %d, total fee %s after %d charges", info.Uptime, info.TotalFee, charged)
+	}
+}
+
+// Top-ups made while the client is being charged are neither lost nor
+// overwritten by a charge working from an older balance.
+func TestConcurrentChargesAndTopUps(t *testing.T) {
+	env := newTestEnv(t)
+	id := env.register(t, "busy@example.com", model.BasicBilling, model.Rupiah(1500000))
+	start := env.info(t, id).Balance
+
+	charged, adjusted := runConcurrently(t, env, func(ctx context.Context, amount int64) error {
+		return env.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			_, err := env.balances.UpdateBalance(ctx, id, model.Rupiah(amount), model.AdjustmentGoodwill)
+			return err
+		})
+	})
+	if charged != concurrentHours {
+		t.Errorf("%d charges, want one per hour (%d)", charged, concurrentHours)
+	}
+	checkConcurrentBalance(t, env, id, start, charged, adjusted)
+	if n := len(env.repo.Transactions(context.Background())); n != charged {
+		t.Errorf("%d transactions, want %d", n, charged)
+	}
+}
+
+func TestConcurrentChargesAndAdjustmentsPostgres(t *testing.T) {
+	env := newPostgresEnv(t)
+	ctx := context.Background()
+	id := env.register(t, "busy@example.com", model.BasicBilling, model.Rupiah(1500000))
+	start := env.info(t, id).Balance
+
+	charged, adjusted := runConcurrently(t, env, func(ctx context.Context, amount int64) error {
+		_, err := env.adjustments.CreateAdjustmentService(ctx, id, &req.NewAdjustment{Amount: amount, ReasonCode: model.AdjustmentGoodwill})
+		return err
+	})
+	// A charge skips an hour whose client row an adjustment holds locked, so
+	// fewer charges than hours is fine; what was charged must add up.
+	if charged == 0 || charged > concurrentHours {
+		t.Errorf("%d charges in %d hours", charged, concurrentHours)
+	}
+	checkConcurrentBalance(t, env, id, start, charged, adjusted)
+
+	// Every change to the balance has a transaction, so they sum to the
+	// difference as well.
+	var charges, adjustments int
+	var total int64
+	err := env.db.QueryRow(ctx, `
+	SELECT count(*) FILTER (WHERE type = $2), count(*) FILTER (WHERE type = $3), COALESCE(SUM(amount + tax_amount), 0)
+	FROM transactions WHERE client_id = $1
+	`, id, model.HourlyChargeTransaction, model.AdjustmentTransaction).Scan(&charges, &adjustments, &total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charges != charged || adjustments != concurrentAdjusters*adjustmentsPerAdjuster {
+		t.Errorf("%d charge and %d adjustment transactions, want %d and %d", charges, adjustments, charged, concurrentAdjusters*adjustmentsPerAdjuster)
+	}
+	if balance := env.info(t, id).Balance; start.Amount-total != balance.Amount {
+		t.Errorf("transactions sum to %d, balance moved by %d", -total, balance.Amount-start.Amount)
+	}
+}
+
+// chargeAll runs every scheduler at once, per client or in batches of
+// batchSize when it is positive, and returns how many charges they made.
+func chargeAll(t *testing.T, schedulers []*TransactionSchedulerService, batchSize int) int {
+	t.Helper()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	var charged atomic.Int64
+	for _, scheduler := range schedulers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			var err error
+			if batchSize > 0 {
+				n, err = scheduler.chargeBatch(ctx, batchSize)
+			} else {
+				n, err = scheduler.ChargeDueClients(ctx)
+			}
+			if err != nil {
+				t.Errorf("charge: %v", err)
+			}
+			charged.Add(int64(n))
+		}()
+	}
+	wg.Wait()
+	return int(charged.Load())
+}
+
+// testConcurrentSchedulers runs several schedulers against the same clients
+// for a few hours and checks each client was charged exactly once an hour.
+func testConcurrentSchedulers(t *testing.T, env *testEnv, batchSize int) {
+	const clients, replicas, hours = 20, 5, 6
+	ids := make([]uuid.UUID, clients)
+	for i := range ids {
+		ids[i] = env.register(t, fmt.Sprintf("client%d@example.com", i), model.BasicBilling, model.Rupiah(1500000))
+	}
+	schedulers := []*TransactionSchedulerService{env.scheduler}
+	for len(schedulers) < replicas {
+		schedulers = append(schedulers, env.newScheduler())
+	}
+
+	for hour := 1; hour <= hours; hour++ {
+		env.clock.Advance(env.billing.Interval)
+		if n := chargeAll(t, schedulers, batchSize); n != clients {
+			t.Fatalf("hour %d: %d charges for %d clients", hour, n, clients)
+		}
+	}
+	for _, id := range ids {
+		info := env.info(t, id)
+		if info.Uptime != hours || info.Balance != model.Rupiah(1485000-hours*basicHourlyCharge) {
+			t.Errorf("%s: uptime %d, balance %s after %d hours", info.Email, info.Uptime, info.Balance, hours)
+		}
+	}
+}
+
+func TestConcurrentSchedulers(t *testing.T) {
+	for _, batchSize := range []int{0, 8} {
+		t.Run(fmt.Sprintf("batch=%d", batchSize), func(t *testing.T) {
+			env := newTestEnv(t)
+			testConcurrentSchedulers(t, env, batchSize)
+
+			billed := make(map[uuid.UUID]map[time.Time]int)
+			for _, tx := range env.repo.Transactions(context.Background()) {
+				if billed[tx.ClientID] == nil {
+					billed[tx.ClientID] = make(map[time.Time]int)
+				}
+				billed[tx.ClientID][tx.PeriodEnd]++
+			}
+			for id, hours := range billed {
+				for end, n := range hours {
+					if n != 1 {
+						t.Errorf("client %s billed %d times for the hour ending %s", id, n, end)
+					}
+				}
+			}
+		})
+	}
+}
+
+// With Postgres the schedulers really overlap, and only the row locks taken
+// by ClaimClient and ChargeDueBatch keep them from charging a client twice.
+func TestConcurrentSchedulersPostgres(t *testing.T) {
+	for _, batchSize := range []int{0, 8} {
+		t.Run(fmt.Sprintf("batch=%d", batchSize), func(t *testing.T) {
+			env := newPostgresEnv(t)
+			testConcurrentSchedulers(t, env, batchSize)
+
+			var doubled int
+			err := env.db.QueryRow(context.Background(), `
+			SELECT count(*) FROM (
+				SELECT 1 FROM transactions WHERE type = $1
+				GROUP BY client_id, period_end HAVING count(*) > 1
+			) d
+			`, model.HourlyChargeTransaction).Scan(&doubled)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doubled != 0 {
+				t.Errorf("%d client-hours billed more than once", doubled)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ExchangeRateServiceImpl interface {
+	RateAt(ctx context.Context, currency model.Currency, at time.Time) (model.Rate, error)
+	RatesAt(ctx context.Context, at time.Time) (map[model.Currency]model.Rate, error)
+	ListExchangeRatesService(ctx context.Context) ([]model.ExchangeRate, error)
+	CreateExchangeRateService(ctx context.Context, input *req.NewExchangeRate) (*model.ExchangeRate, error)
+	LoadExchangeRatesFile(ctx context.Context, path string) (int, error)
+}
+
+type ExchangeRateService struct {
+	repo   repository.ExchangeRateRepoImpl
+	clock  utils.Clock
+	logger *zap.Logger
+}
+
+func NewExchangeRateService(repo repository.ExchangeRateRepoImpl, clock utils.Clock, logger *zap.Logger) *ExchangeRateService {
+	return &ExchangeRateService{
+		repo:   repo,
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+// RateAt returns the rupiah value of one unit of currency at the given time.
+// Rupiah itself always converts at IdentityRate.
+func (es *ExchangeRateService) RateAt(ctx context.Context, currency model.Currency, at time.Time) (model.Rate, error) {
+	ctx, span := tracing.Start(ctx, "ExchangeRateService.RateAt")
+	defer span.End()
+	if currency == model.DefaultCurrency {
+		return model.IdentityRate, nil
+	}
+	rate, err := es.repo.GetExchangeRateAt(ctx, currency, at)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+// RatesAt returns the rate of every supported currency that has one at the
+// given time.
+func (es *ExchangeRateService) RatesAt(ctx context.Context, at time.Time) (map[model.Currency]model.Rate, error) {
+	ctx, span := tracing.Start(ctx, "ExchangeRateService.RatesAt")
+	defer span.End()
+	rates := make(map[model.Currency]model.Rate)
+	for currency := range model.Prices {
+		rate, err := es.RateAt(ctx, currency, at)
+		if errors.Is(err, utils.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+func (es *ExchangeRateService) ListExchangeRatesService(ctx context.Context) ([]model.ExchangeRate, error) {
+	ctx, span := tracing.Start(ctx, "ExchangeRateService.ListExchangeRatesService")
+	defer span.End()
+	return es.repo.ListExchangeRates(ctx)
+}
+
+func (es *ExchangeRateService) CreateExchangeRateService(ctx context.Context, input *req.NewExchangeRate) (*model.ExchangeRate, error) {
+	ctx, span := tracing.Start(ctx, "ExchangeRateService.CreateExchangeRateService")
+	defer span.End()
+	rate := &model.ExchangeRate{
+		RateID:        uuid.New(),
+		Currency:      input.Currency,
+		Rate:          input.Rate,
+		EffectiveFrom: input.EffectiveFrom,
+		CreatedAt:     es.clock.Now(),
+	}
+	if err := es.repo.CreateExchangeRate(ctx, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// LoadExchangeRatesFile adds the rates in a JSON file holding an array of
+// {"currency", "rate", "effective_from"} objects, the same shape the admin
+// API accepts. Rates already present are skipped, so the same file can be
+// loaded on every start. It returns how many rates were added.
+func (es *ExchangeRateService) LoadExchangeRatesFile(ctx context.Context, path string) (int, error) {
+	ctx, span := tracing.Start(ctx, "ExchangeRateService.LoadExchangeRatesFile")
+	defer span.End()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read exchange rates file: %w", err)
+	}
+	var inputs []req.NewExchangeRate
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return 0, fmt.Errorf("failed to parse exchange rates file: %v: %w", err, utils.ErrBadRequest)
+	}
+	added := 0
+	for i := range inputs {
+		if err := inputs[i].Validate(); err != nil {
+			return added, fmt.Errorf("exchange rate %d: %w", i, err)
+		}
+		_, err := es.CreateExchangeRateService(ctx, &inputs[i])
+		if errors.Is(err, utils.ErrExists) {
+			continue
+		} else if err != nil {
+			return added, err
+		}
+		added++
+	}
+	utils.Log(ctx, es.logger).Info("Exchange rates loaded", zap.String("file", path), zap.Int("added", added), zap.Int("total", len(inputs)))
+	return added, nil
+}
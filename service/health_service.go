@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/migration"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"go.uber.org/zap"
+)
+
+type HealthServiceImpl interface {
+	ReadinessService(ctx context.Context) *res.Health
+}
+
+// Pinger is satisfied by *pgxpool.Pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthService struct {
+	db        Pinger
+	migrator  migration.MigratorImpl
+	scheduler TransactionSchedulerServiceImpl
+	// window is how long the scheduler may go without finishing a cycle
+	// before the app is reported as not ready.
+	window time.Duration
+	clock  utils.Clock
+	logger *zap.Logger
+}
+
+func NewHealthService(db Pinger, migrator migration.MigratorImpl, scheduler TransactionSchedulerServiceImpl, window time.Duration, clock utils.Clock, logger *zap.Logger) *HealthService {
+	return &HealthService{
+		db:        db,
+		migrator:  migrator,
+		scheduler: scheduler,
+		window:    window,
+		clock:     clock,
+		logger:    logger,
+	}
+}
+
+// ReadinessService checks the database, the schema and the scheduler. The
+// app is ready only when all of them are up.
+func (hs *HealthService) ReadinessService(ctx context.Context) *res.Health {
+	health := &res.Health{
+		Status: res.StatusUp,
+		Components: map[string]res.ComponentHealth{
+			"database":   hs.database(ctx),
+			"migrations": hs.migrations(ctx),
+			"scheduler":  hs.schedulerCycle(),
+		},
+	}
+	for name, component := range health.Components {
+		if component.Status != res.StatusUp {
+			health.Status = res.StatusDown
+			utils.Log(ctx, hs.logger).Warn("Readiness check failed", zap.String("component", name), zap.String("warn", component.Error))
+		}
+	}
+	return health
+}
+
+func (hs *HealthService) database(ctx context.Context) res.ComponentHealth {
+	start := time.Now()
+	if err := hs.db.Ping(ctx); err != nil {
+		return res.ComponentHealth{Status: res.StatusDown, Error: err.Error()}
+	}
+	return res.ComponentHealth{Status: res.StatusUp, Detail: map[string]string{"latency": time.Since(start).String()}}
+}
+
+func (hs *HealthService) migrations(ctx context.Context) res.ComponentHealth {
+	pending, err := hs.migrator.Pending(ctx)
+	if err != nil {
+		return res.ComponentHealth{Status: res.StatusDown, Error: err.Error()}
+	}
+	detail := map[string]int{"pending": pending}
+	if pending > 0 {
+		return res.ComponentHealth{Status: res.StatusDown, Error: fmt.Sprintf("%d migrations not applied", pending), Detail: detail}
+	}
+	return res.ComponentHealth{Status: res.StatusUp, Detail: detail}
+}
+
+func (hs *HealthService) schedulerCycle() res.ComponentHealth {
+	last := hs.scheduler.LastCycle()
+	if last.IsZero() {
+		return res.ComponentHealth{Status: res.StatusDown, Error: "no billing cycle has completed yet"}
+	}
+	age := hs.clock.Now().Sub(last)
+	detail := map[string]any{"last_cycle": last, "window": hs.window.String()}
+	if age > hs.window {
+		return res.ComponentHealth{Status: res.StatusDown, Error: fmt.Sprintf("last billing cycle finished %s ago", age.Round(time.Second)), Detail: detail}
+	}
+	return res.ComponentHealth{Status: res.StatusUp, Detail: detail}
+}
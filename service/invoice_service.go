@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type InvoiceServiceImpl interface {
+	InvoiceWorkerService(ctx context.Context, interval time.Duration)
+	GenerateInvoicesService(ctx context.Context, periodStart time.Time) (int, error)
+	ListInvoicesService(ctx context.Context, clientID uuid.UUID) ([]model.Invoice, error)
+	GetInvoiceService(ctx context.Context, clientID, invoiceID uuid.UUID) (*model.Invoice, error)
+	UpdateInvoiceStatusService(ctx context.Context, invoiceID uuid.UUID, input *req.InvoiceStatus) (*model.Invoice, error)
+}
+
+type InvoiceService struct {
+	transactor repository.TransactorImpl
+	repo       repository.InvoiceRepoImpl
+	clock      utils.Clock
+	logger     *zap.Logger
+}
+
+func NewInvoiceService(transactor repository.TransactorImpl, repo repository.InvoiceRepoImpl, clock utils.Clock, logger *zap.Logger) *InvoiceService {
+	return &InvoiceService{
+		transactor: transactor,
+		repo:       repo,
+		clock:      clock,
+		logger:     logger,
+	}
+}
+
+// InvoiceWorkerService invoices the previous month on start and then on every
+// tick. Generation is idempotent, so a tick that finds everything invoiced
+// does nothing.
+func (is *InvoiceService) InvoiceWorkerService(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		periodStart := model.MonthStart(is.clock.Now()).AddDate(0, -1, 0)
+		runCtx := utils.WithLogFields(ctx, zap.String("run_id", uuid.NewString()))
+		if _, err := is.GenerateInvoicesService(runCtx, periodStart); err != nil {
+			info := "failed to generate invoices"
+			utils.Log(runCtx, is.logger).Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			utils.Log(ctx, is.logger).Info("Stopping invoice worker")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GenerateInvoicesService creates an invoice for every billing with activity in
+// the month starting at periodStart and returns how many were created.
+func (is *InvoiceService) GenerateInvoicesService(ctx context.Context, periodStart time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "InvoiceService.GenerateInvoicesService")
+	defer span.End()
+	periodStart = model.MonthStart(periodStart)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+
+	candidates, err := is.repo.GetInvoiceCandidates(ctx, periodStart, periodEnd)
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := is.createInvoice(ctx, &candidate, periodStart, periodEnd)
+		if err != nil {
+			utils.Log(ctx, is.logger).Error(utils.ErrInternal.Error(), zap.String("error", "failed to create invoice"),
+				zap.String("billing_id", candidate.BillingID.String()), zap.Error(err))
+			continue
+		}
+		if ok {
+			created++
+		}
+	}
+	if created > 0 {
+		utils.Log(ctx, is.logger).Info("Invoices generated", zap.Time("period_start", periodStart), zap.Int("count", created))
+	}
+	return created, nil
+}
+
+func (is *InvoiceService) createInvoice(ctx context.Context, candidate *model.InvoiceCandidate, periodStart, periodEnd time.Time) (bool, error) {
+	created := false
+	err := is.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		summaries, err := is.repo.SummarizeTransactions(ctx, candidate.BillingID, periodStart, periodEnd)
+		if err != nil {
+			return err
+		}
+		invoice, err := is.buildInvoice(candidate, summaries, periodStart, periodEnd)
+		if err != nil {
+			return err
+		}
+		if len(invoice.Lines) == 0 {
+			return nil
+		}
+		if err := is.repo.CreateInvoice(ctx, invoice); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+func (is *InvoiceService) buildInvoice(candidate *model.InvoiceCandidate, summaries []model.TransactionSummary, periodStart, periodEnd time.Time) (*model.Invoice, error) {
+	now := is.clock.Now()
+	zero := model.Money{Currency: candidate.Currency}
+	invoice := &model.Invoice{
+		InvoiceID:   uuid.New(),
+		ClientID:    candidate.ClientID,
+		TaxID:       candidate.TaxID,
+		BillingID:   candidate.BillingID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Status:      model.InvoiceOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Subtotal:    zero,
+		TaxTotal:    zero,
+		Total:       zero,
+	}
+	addLine := func(lineType, description string, quantity int, unitPrice, amount, taxAmount model.Money) error {
+		subtotal, err := invoice.Subtotal.Add(amount)
+		if err != nil {
+			return err
+		}
+		taxTotal, err := invoice.TaxTotal.Add(taxAmount)
+		if err != nil {
+			return err
+		}
+		total, err := subtotal.Add(taxTotal)
+		if err != nil {
+			return err
+		}
+		invoice.Lines = append(invoice.Lines, model.InvoiceLine{
+			LineID:      uuid.New(),
+			InvoiceID:   invoice.InvoiceID,
+			Type:        lineType,
+			Description: description,
+			Quantity:    quantity,
+			UnitPrice:   unitPrice,
+			Amount:      amount,
+			TaxAmount:   taxAmount,
+		})
+		invoice.Subtotal, invoice.TaxTotal, invoice.Total = subtotal, taxTotal, total
+		return nil
+	}
+
+	// The down payment is the amount stored at registration, so later plan
+	// price changes don't alter what the client is shown to have paid.
+	if candidate.DownPayment.Amount > 0 && !candidate.ClientCreated.Before(periodStart) && candidate.ClientCreated.Before(periodEnd) {
+		err := addLine(model.DownPaymentLine, fmt.Sprintf("Down payment, %s plan", candidate.Plan), 1, candidate.DownPayment, candidate.DownPayment, zero)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, summary := range summaries {
+		var err error
+		switch summary.Type {
+		case model.HourlyChargeTransaction:
+			err = addLine(model.ComputeLine, "Compute hours", summary.Count, summary.Amount.Div(int64(summary.Count)), summary.Amount, summary.TaxAmount)
+		case model.AdjustmentTransaction:
+			err = addLine(model.AdjustmentLine, "Manual adjustments", summary.Count, zero, summary.Amount, summary.TaxAmount)
+		default:
+			err = addLine(model.AdjustmentLine, fmt.Sprintf("Adjustments (%s)", summary.Type), summary.Count, zero, summary.Amount, summary.TaxAmount)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return invoice, nil
+}
+
+func (is *InvoiceService) ListInvoicesService(ctx context.Context, clientID uuid.UUID) ([]model.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "InvoiceService.ListInvoicesService")
+	defer span.End()
+	return is.repo.ListInvoices(ctx, clientID)
+}
+
+func (is *InvoiceService) GetInvoiceService(ctx context.Context, clientID, invoiceID uuid.UUID) (*model.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "InvoiceService.GetInvoiceService")
+	defer span.End()
+	return is.repo.GetInvoice(ctx, clientID, invoiceID)
+}
+
+func (is *InvoiceService) UpdateInvoiceStatusService(ctx context.Context, invoiceID uuid.UUID, input *req.InvoiceStatus) (*model.Invoice, error) {
+	ctx, span := tracing.Start(ctx, "InvoiceService.UpdateInvoiceStatusService")
+	defer span.End()
+	return is.repo.UpdateInvoiceStatus(ctx, invoiceID, input.Status)
+}
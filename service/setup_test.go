@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/config"
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/model/res"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/repository/memory"
+	"github.com/bagasadiii/maxcloud_vps/repository/pgtest"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// testEnv wires the services to the in-memory repositories, or to Postgres
+// with newPostgresEnv, on a fake clock starting at epoch, with 11% PPN and
+// fixed SGD and USD rates.
+type testEnv struct {
+	clock *utils.FakeClock
+	// store and repo are only set for the in-memory repositories.
+	store *memory.Store
+	repo  *memory.TransactionSchedulerRepo
+	// db and adjustments are only set for Postgres.
+	db          *pgxpool.Pool
+	adjustments *AdjustmentService
+
+	transactor repository.TransactorImpl
+	balances   repository.TransactionSchedulerRepoImpl
+	clients    *ClientService
+	tax        *TaxService
+	fx         *ExchangeRateService
+	billing    config.BillingConfig
+	scheduler  *TransactionSchedulerService
+}
+
+var epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestEnv(t testing.TB) *testEnv {
+	t.Helper()
+	env := newEnv()
+	env.store = memory.NewStore()
+	env.repo = memory.NewTransactionSchedulerRepo(env.store, env.clock)
+	env.transactor, env.balances = env.store, env.repo
+	env.clients = NewClientService(memory.NewClientRepo(env.store), env.clock, zap.NewNop())
+	env.scheduler = env.newScheduler()
+	return env
+}
+
+// newPostgresEnv is newTestEnv on the repositories backed by a schema of
+// its own in TESTDATABASELINK. The test is skipped when that is not set.
+func newPostgresEnv(t testing.TB) *testEnv {
+	t.Helper()
+	logger := zap.NewNop()
+	env := newEnv()
+	env.db = pgtest.Open(t)
+	env.transactor = repository.NewTransactor(env.db, logger)
+	env.balances = repository.NewTransactionSchedulerRepo(env.db, env.clock, logger)
+	env.clients = NewClientService(repository.NewClientRepo(env.db, logger), env.clock, logger)
+	env.adjustments = NewAdjustmentService(env.transactor, repository.NewAdjustmentRepo(env.db, logger), env.balances, env.fx, env.clock, logger)
+	env.scheduler = env.newScheduler()
+	return env
+}
+
+func newEnv() *testEnv {
+	logger := zap.NewNop()
+	clock := utils.NewFakeClock(epoch)
+	return &testEnv{
+		clock: clock,
+		tax: NewTaxService(memory.NewTaxRepo(model.TaxRule{
+			Name: model.PPN, RateBps: 1100, EffectiveFrom: time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC),
+		}), clock, logger),
+		fx: NewExchangeRateService(memory.NewExchangeRateRepo(
+			model.ExchangeRate{Currency: model.SGD, Rate: 12000 * model.IdentityRate, EffectiveFrom: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+			model.ExchangeRate{Currency: model.USD, Rate: 16000 * model.IdentityRate, EffectiveFrom: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		), clock, logger),
+		billing: config.Default().Billing,
+	}
+}
+
+// newScheduler returns another scheduler sharing env's repositories, as a
+// second replica would.
+func (env *testEnv) newScheduler() *TransactionSchedulerService {
+	return NewTransactionSchedulerService(env.transactor, env.balances, env.tax, env.fx, env.billing, env.clock, zap.NewNop())
+}
+
+// register creates a client through ClientService and returns its id.
+func (env *testEnv) register(t testing.TB, email, plan string, balance model.Money) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	input := &req.NewClient{Email: email, Balance: balance.Amount, Currency: balance.Currency, Plan: plan}
+	if err := input.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.clients.CreateClientService(ctx, input); err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+	list, err := env.clients.ListClientsService(ctx, &req.ClientFilter{SortBy: req.SortCreatedAt, Limit: req.MaxListLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range list.Clients {
+		if client.Email == input.Email {
+			return client.ClientID
+		}
+	}
+	t.Fatalf("registered client %s not listed", email)
+	return uuid.Nil
+}
+
+// advance moves the clock one billing interval and charges every due client.
+func (env *testEnv) advance(t testing.TB) int {
+	t.Helper()
+	env.clock.Advance(env.billing.Interval)
+	n, err := env.scheduler.ChargeDueClients(context.Background())
+	if err != nil {
+		t.Fatalf("charge at %s: %v", env.clock.Now().Format(time.RFC3339), err)
+	}
+	return n
+}
+
+func (env *testEnv) info(t testing.TB, clientID uuid.UUID) *res.ClientInfo {
+	t.Helper()
+	info, err := env.clients.GetClientInfoService(context.Background(), clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/google/uuid"
+)
+
+// TestSimulateMonth bills a few clients hour by hour for 30 days on the fake
+// clock, as the scheduler would, and checks where each one ends up.
+func TestSimulateMonth(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		email   string
+		plan    string
+		balance model.Money
+		// hours is how many hours get billed, which for a suspended client is
+		// also the hour it is suspended at.
+		hours       int
+		suspended   bool
+		wantBalance model.Money
+		id          uuid.UUID
+	}{
+		// Registered with exactly a month left after the 15000 down payment,
+		// 1440000 runs out at 2220 an hour in hour 649.
+		{email: "basic@example.com", plan: model.BasicBilling, balance: model.Rupiah(1455000),
+			hours: 649, suspended: true, wantBalance: model.Rupiah(1440000 - 649*2220)},
+		// 4000 an hour plus 440 PPN lasts the whole month.
+		{email: "normal@example.com", plan: model.NormalBilling, balance: model.Rupiah(5000000),
+			hours: 720, wantBalance: model.Rupiah(5000000 - 25000 - 720*4440)},
+		{email: "premium@example.com", plan: model.PremiumBilling, balance: model.Rupiah(4000000),
+			hours: 720, wantBalance: model.Rupiah(4000000 - 40000 - 720*5328)},
+		// 10 cents an hour with 1.1 cents of tax carried between hours: after
+		// 649 hours 6490 + 713 exceeds the 7200 left after the down payment.
+		{email: "usd@example.com", plan: model.BasicBilling, balance: model.NewMoney(7300, model.USD),
+			hours: 649, suspended: true, wantBalance: model.NewMoney(7200-6490-713, model.USD)},
+	}
+	for i := range tests {
+		tests[i].id = env.register(t, tests[i].email, tests[i].plan, tests[i].balance)
+	}
+
+	const days = 30
+	suspendedAt := make(map[uuid.UUID]int)
+	charges := 0
+	for hour := 1; hour <= days*24; hour++ {
+		charges += env.advance(t)
+		for _, tt := range tests {
+			if _, ok := suspendedAt[tt.id]; !ok && env.info(t, tt.id).Suspended {
+				suspendedAt[tt.id] = hour
+			}
+		}
+	}
+
+	wantCharges := 0
+	for _, tt := range tests {
+		wantCharges += tt.hours
+		info := env.info(t, tt.id)
+		if info.Balance != tt.wantBalance {
+			t.Errorf("%s: balance = %s, want %s", tt.email, info.Balance, tt.wantBalance)
+		}
+		if info.Uptime != tt.hours {
+			t.Errorf("%s: billed %d hours, want %d", tt.email, info.Uptime, tt.hours)
+		}
+		hour, suspended := suspendedAt[tt.id]
+		if suspended != tt.suspended {
+			t.Errorf("%s: suspended = %t, want %t", tt.email, suspended, tt.suspended)
+		} else if suspended && hour != tt.hours {
+			t.Errorf("%s: suspended in hour %d, want %d", tt.email, hour, tt.hours)
+		}
+	}
+	if charges != wantCharges {
+		t.Errorf("%d hourly charges, want %d", charges, wantCharges)
+	}
+	if n := len(env.repo.Transactions(ctx)); n != wantCharges {
+		t.Errorf("%d transactions, want %d", n, wantCharges)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
+	"github.com/bagasadiii/maxcloud_vps/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type TaxServiceImpl interface {
+	TaxFor(ctx context.Context, amount model.Money, exempt bool, at time.Time, carry int64) (model.Money, int64, error)
+	RateBpsAt(ctx context.Context, at time.Time) (int, error)
+	ListTaxRulesService(ctx context.Context) ([]model.TaxRule, error)
+	CreateTaxRuleService(ctx context.Context, input *req.NewTaxRule) (*model.TaxRule, error)
+}
+
+type TaxService struct {
+	repo   repository.TaxRepoImpl
+	clock  utils.Clock
+	logger *zap.Logger
+}
+
+func NewTaxService(repo repository.TaxRepoImpl, clock utils.Clock, logger *zap.Logger) *TaxService {
+	return &TaxService{
+		repo:   repo,
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+// TaxFor returns the PPN owed on a tax-exclusive amount at the given time,
+// and the carry to pass to the next charge of the same billing (see
+// model.TaxRule.Calculate). Exempt clients and periods without a PPN rule
+// owe nothing and keep their carry.
+func (ts *TaxService) TaxFor(ctx context.Context, amount model.Money, exempt bool, at time.Time, carry int64) (model.Money, int64, error) {
+	ctx, span := tracing.Start(ctx, "TaxService.TaxFor")
+	defer span.End()
+	none := model.Money{Currency: amount.Currency}
+	if exempt {
+		return none, carry, nil
+	}
+	rule, err := ts.repo.GetTaxRuleAt(ctx, model.PPN, at)
+	if errors.Is(err, utils.ErrNotFound) {
+		return none, carry, nil
+	} else if err != nil {
+		return model.Money{}, 0, err
+	}
+	return rule.Calculate(amount, carry)
+}
+
+// RateBpsAt returns the PPN rate in effect at the given time, 0 if there is none.
+func (ts *TaxService) RateBpsAt(ctx context.Context, at time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "TaxService.RateBpsAt")
+	defer span.End()
+	rule, err := ts.repo.GetTaxRuleAt(ctx, model.PPN, at)
+	if errors.Is(err, utils.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return rule.RateBps, nil
+}
+
+func (ts *TaxService) ListTaxRulesService(ctx context.Context) ([]model.TaxRule, error) {
+	ctx, span := tracing.Start(ctx, "TaxService.ListTaxRulesService")
+	defer span.End()
+	return ts.repo.ListTaxRules(ctx)
+}
+
+func (ts *TaxService) CreateTaxRuleService(ctx context.Context, input *req.NewTaxRule) (*model.TaxRule, error) {
+	ctx, span := tracing.Start(ctx, "TaxService.CreateTaxRuleService")
+	defer span.End()
+	rule := &model.TaxRule{
+		RuleID:        uuid.New(),
+		Name:          input.Name,
+		RateBps:       input.RateBps,
+		EffectiveFrom: input.EffectiveFrom,
+		EffectiveTo:   input.EffectiveTo,
+		CreatedAt:     ts.clock.Now(),
+	}
+	if err := ts.repo.CreateTaxRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
@@ -2,133 +2,443 @@ package service
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bagasadiii/maxcloud_vps/config"
+	"github.com/bagasadiii/maxcloud_vps/metrics"
 	"github.com/bagasadiii/maxcloud_vps/model"
 	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/bagasadiii/maxcloud_vps/tracing"
 	"github.com/bagasadiii/maxcloud_vps/utils"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 type TransactionSchedulerServiceImpl interface {
-	SchedulerWorkerService(ctx context.Context, worker int)
+	SchedulerWorkerService(ctx context.Context, worker int, tick time.Duration)
+	BatchSchedulerService(ctx context.Context, worker int, tick time.Duration, batchSize int)
+	ChargeDueClients(ctx context.Context) (int, error)
+	LastCycle() time.Time
 }
 type TransactionSchedulerService struct {
-	db     *pgxpool.Pool
-	repo   repository.TransactionSchedulerRepoImpl
-	logger *zap.Logger
+	transactor repository.TransactorImpl
+	repo       repository.TransactionSchedulerRepoImpl
+	tax        TaxServiceImpl
+	fx         ExchangeRateServiceImpl
+	billing    config.BillingConfig
+	clock      utils.Clock
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]struct{}
+	// lastCycle is the clock time, in unix nanoseconds, at which the
+	// scheduler last finished a run without error.
+	lastCycle atomic.Int64
 }
 
-func NewTransactionSchedulerService(db *pgxpool.Pool, repo repository.TransactionSchedulerRepoImpl, logger *zap.Logger) *TransactionSchedulerService {
+func NewTransactionSchedulerService(transactor repository.TransactorImpl, repo repository.TransactionSchedulerRepoImpl, tax TaxServiceImpl, fx ExchangeRateServiceImpl, billing config.BillingConfig, clock utils.Clock, logger *zap.Logger) *TransactionSchedulerService {
 	return &TransactionSchedulerService{
-		db:     db,
-		repo:   repo,
-		logger: logger,
+		transactor: transactor,
+		repo:       repo,
+		tax:        tax,
+		fx:         fx,
+		billing:    billing,
+		clock:      clock,
+		logger:     logger,
+		inFlight:   make(map[uuid.UUID]struct{}),
 	}
 }
 
-func (hs *TransactionSchedulerService) SchedulerWorkerService(ctx context.Context, worker int) {
-	jobs := make(chan *model.UpdateClient, 100)
+// schedulerActor is who charges and suspensions are recorded as made by.
+var schedulerActor = utils.Actor{Type: utils.ActorSystem, ID: "billing-scheduler"}
+
+// chargeJob is one client queued for a worker by the scheduler run runID.
+type chargeJob struct {
+	runID  string
+	client *model.UpdateClient
+}
+
+// SchedulerWorkerService looks for clients due for billing roughly every tick
+// (with up to 10% jitter, so replicas don't poll in lockstep) and hands them
+// to a pool of workers. It returns once ctx is done and every worker has
+// finished its current client.
+func (hs *TransactionSchedulerService) SchedulerWorkerService(ctx context.Context, worker int, tick time.Duration) {
+	ctx = utils.WithActor(ctx, schedulerActor)
+	jobs := make(chan chargeJob, 100)
+	var wg sync.WaitGroup
 
 	for i := 0; i < worker; i++ {
+		wg.Add(1)
 		go func(id int) {
-			hs.logger.Info("Worker started", zap.Int("worker_id", id))
-			for client := range jobs {
-				hs.logger.Info("Worker processing transaction", zap.String("client_id", client.ClientID.String()))
-				if err := hs.transactionService(ctx, client); err != nil {
+			defer wg.Done()
+			utils.Log(ctx, hs.logger).Info("Worker started", zap.Int("worker_id", id))
+			for job := range jobs {
+				metrics.QueueDepth(len(jobs))
+				client := job.client
+				// Clients still queued at shutdown are picked up by the next run.
+				if ctx.Err() != nil {
+					hs.release(client.ClientID)
+					continue
+				}
+				jobCtx := utils.WithLogFields(ctx, zap.String("run_id", job.runID), zap.String("job_id", uuid.NewString()),
+					zap.Int("worker_id", id))
+				utils.Log(jobCtx, hs.logger).Info("Worker processing transaction", zap.String("client_id", client.ClientID.String()))
+				charged, err := hs.transactionService(jobCtx, client)
+				if err != nil {
 					info := "failed to process transaction"
-					hs.logger.Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err), zap.Any("client", client))
+					utils.Log(jobCtx, hs.logger).Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err), zap.Any("client", client))
+				} else if charged {
+					utils.Log(jobCtx, hs.logger).Info("Transaction success, balance deducted", zap.Any("client", client))
+				} else {
+					utils.Log(jobCtx, hs.logger).Info("Client already charged or claimed elsewhere, skipped", zap.String("client_id", client.ClientID.String()))
 				}
-				hs.logger.Info("Transaction success, balance deducted", zap.Any("client", client))
+				hs.release(client.ClientID)
 			}
 		}(i)
 	}
 
-	hs.logger.Info("Worker pool started")
+	utils.Log(ctx, hs.logger).Info("Worker pool started", zap.Int("workers", worker), zap.Duration("tick", tick))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			hs.logger.Info("Stopping worker")
+			utils.Log(ctx, hs.logger).Info("Stopping worker")
+			close(jobs)
+			wg.Wait()
+			utils.Log(ctx, hs.logger).Info("Worker pool stopped")
 			return
-		default:
+		case <-timer.C:
 			hs.schedulerService(ctx, jobs)
+			timer.Reset(jitter(tick))
+		}
+	}
+}
+
+// BatchSchedulerService is the set-based alternative to SchedulerWorkerService
+// for large client counts. On every tick each worker charges due clients
+// batchSize at a time, one transaction per batch, until none are left.
+// Batches lock with SKIP LOCKED, so workers and replicas never overlap.
+func (hs *TransactionSchedulerService) BatchSchedulerService(ctx context.Context, worker int, tick time.Duration, batchSize int) {
+	ctx = utils.WithActor(ctx, schedulerActor)
+	utils.Log(ctx, hs.logger).Info("Batch billing started", zap.Int("workers", worker), zap.Int("batch_size", batchSize), zap.Duration("tick", tick))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			utils.Log(ctx, hs.logger).Info("Batch billing stopped")
+			return
+		case <-timer.C:
+			hs.batchRun(utils.WithLogFields(ctx, zap.String("run_id", uuid.NewString())), worker, batchSize)
+			timer.Reset(jitter(tick))
 		}
 	}
 }
 
-func (hs *TransactionSchedulerService) schedulerService(ctx context.Context, jobs chan<- *model.UpdateClient) {
-	clients, err := hs.repo.GetActiveClient(ctx)
+func (hs *TransactionSchedulerService) batchRun(ctx context.Context, worker int, batchSize int) {
+	start := time.Now()
+	var charged atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i := 0; i < worker; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				jobCtx := utils.WithLogFields(ctx, zap.String("job_id", uuid.NewString()))
+				n, err := hs.chargeBatch(jobCtx, batchSize)
+				if err != nil {
+					info := "failed to charge batch"
+					utils.Log(jobCtx, hs.logger).Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err))
+					failed.Store(true)
+					return
+				}
+				charged.Add(int64(n))
+				if n < batchSize {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := charged.Load(); n > 0 {
+		utils.Log(ctx, hs.logger).Info("Batch billing run finished", zap.Int64("charged", n), zap.Duration("took", time.Since(start)))
+	}
+	if !failed.Load() && ctx.Err() == nil {
+		hs.lastCycle.Store(hs.clock.Now().UnixNano())
+	}
+}
+
+func (hs *TransactionSchedulerService) chargeBatch(ctx context.Context, batchSize int) (n int, err error) {
+	ctx, cancel := hs.detach(ctx)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "billing.charge_batch", attribute.Int("batch_size", batchSize))
+	defer func() {
+		span.SetAttributes(attribute.Int("charged", n))
+		tracing.End(span, err)
+	}()
+	now := hs.clock.Now()
+	rate, err := hs.tax.RateBpsAt(ctx, now)
 	if err != nil {
-		info := "failed to get clients"
-		hs.logger.Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err))
-		return
+		return 0, err
+	}
+	fxRates, err := hs.fx.RatesAt(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	var results []model.ChargeResult
+	err = hs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		results, err = hs.repo.ChargeDueBatch(ctx, now.Add(-hs.billing.Interval), now, batchSize, rate, fxRates)
+		return err
+	})
+	metrics.ChargeDuration(metrics.ModeBatch, time.Since(start))
+	if err != nil {
+		metrics.ChargeResult(metrics.ModeBatch, metrics.ChargeFailed)
+		return 0, err
 	}
-	for _, client := range clients {
-		clientCopy := client
-		now := time.Now()
 
-		if now.Sub(client.UpdatedAt).Hours() >= 1 {
-			jobs <- &clientCopy
+	for _, result := range results {
+		metrics.Charged(metrics.ModeBatch, result.Plan, result.Amount)
+		if result.Suspended {
+			metrics.Suspended(result.Plan)
+			utils.Log(ctx, hs.logger).Warn("Client suspended", zap.Any("client", result))
+		} else if low, err := hs.lowBalance(result.BalanceAfter, result.MonthlyFee); err != nil {
+			info := "failed to check balance threshold"
+			utils.Log(ctx, hs.logger).Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err), zap.Any("client", result))
+		} else if low {
+			utils.Log(ctx, hs.logger).Warn("Client balance is low", zap.Any("client", result), zap.Int("threshold_bps", hs.billing.LowBalanceBps))
 		}
 	}
+	return len(results), nil
 }
 
-func (hs *TransactionSchedulerService) transactionService(ctx context.Context, data *model.UpdateClient) error {
-	tx, err := hs.db.Begin(ctx)
+// ChargeDueClients charges every client that is due right now, one after
+// another, and returns how many were charged. It is the synchronous
+// counterpart of a single scheduler tick.
+func (hs *TransactionSchedulerService) ChargeDueClients(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "TransactionSchedulerService.ChargeDueClients")
+	defer span.End()
+	ctx = utils.WithLogFields(utils.WithActor(ctx, schedulerActor), zap.String("run_id", uuid.NewString()))
+	clients, err := hs.repo.GetActiveClient(ctx, hs.dueBefore())
 	if err != nil {
-		info := "failed to begin transaction"
-		hs.logger.Error(utils.ErrDatabase.Error(), zap.String("error", info), zap.Error(err))
-		return fmt.Errorf("%s: %w", info, utils.ErrDatabase)
+		return 0, err
 	}
-
-	defer func() {
+	charged := 0
+	for i := range clients {
+		jobCtx := utils.WithLogFields(ctx, zap.String("job_id", uuid.NewString()))
+		ok, err := hs.transactionService(jobCtx, &clients[i])
 		if err != nil {
-			hs.logger.Error(utils.ErrDatabase.Error(), zap.String("error", "rolling back transaction"), zap.Error(err))
-			tx.Rollback(ctx)
+			return charged, err
+		}
+		if ok {
+			charged++
 		}
-	}()
-
-	threshold := int(float64(data.MonthlyFee) * 0.10)
-	if data.Balance < threshold {
-		hs.logger.Warn("Client have less than 10% of monthly fee", zap.Any("client", data))
 	}
+	return charged, nil
+}
 
-	newBalance := data.Balance - data.CostPerHour
-	err = hs.repo.UpdateBalance(ctx, tx, data.ClientID, newBalance)
+func (hs *TransactionSchedulerService) schedulerService(ctx context.Context, jobs chan<- chargeJob) {
+	runID := uuid.NewString()
+	ctx = utils.WithLogFields(ctx, zap.String("run_id", runID))
+	ctx, span := tracing.Start(ctx, "billing.schedule", attribute.String("run_id", runID))
+	defer span.End()
+	clients, err := hs.repo.GetActiveClient(ctx, hs.dueBefore())
+	span.SetAttributes(attribute.Int("due", len(clients)))
 	if err != nil {
-		return err
+		tracing.Fail(span, err)
+		info := "failed to get clients"
+		utils.Log(ctx, hs.logger).Error(utils.ErrInternal.Error(), zap.String("error", info), zap.Error(err))
+		return
 	}
+	for _, client := range clients {
+		clientCopy := client
+		if !hs.claim(client.ClientID) {
+			continue
+		}
+		select {
+		case jobs <- chargeJob{runID: runID, client: &clientCopy}:
+			metrics.QueueDepth(len(jobs))
+		case <-ctx.Done():
+			hs.release(client.ClientID)
+			return
+		}
+	}
+	hs.lastCycle.Store(hs.clock.Now().UnixNano())
+}
 
-	newFee := data.TotalFee + data.CostPerHour
-	err = hs.repo.UpdateTotalFee(ctx, tx, data.BillingID, newFee)
-	if err != nil {
-		return err
+// LastCycle returns when the scheduler last looked for due clients and
+// queued or charged all of them, or the zero time if it never has.
+func (hs *TransactionSchedulerService) LastCycle() time.Time {
+	n := hs.lastCycle.Load()
+	if n == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, n)
+}
 
-	err = hs.repo.UpdateClientInfo(ctx, tx, data.ClientID)
-	if err != nil {
-		return err
+// claim marks a client as queued. It reports false if the client is already
+// queued or being charged.
+func (hs *TransactionSchedulerService) claim(clientID uuid.UUID) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, ok := hs.inFlight[clientID]; ok {
+		return false
 	}
+	hs.inFlight[clientID] = struct{}{}
+	return true
+}
+
+func (hs *TransactionSchedulerService) release(clientID uuid.UUID) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	delete(hs.inFlight, clientID)
+}
+
+// dueBefore returns the last-charged time at or before which a client is due.
+func (hs *TransactionSchedulerService) dueBefore() time.Time {
+	return hs.clock.Now().Add(-hs.billing.Interval)
+}
 
-	newUptime := data.Uptime + 1
-	err = hs.repo.UpdateBillingInfo(ctx, tx, data.BillingID, newUptime)
+// lowBalance reports whether balance has dropped below the configured share
+// of monthlyFee.
+func (hs *TransactionSchedulerService) lowBalance(balance, monthlyFee model.Money) (bool, error) {
+	threshold, err := monthlyFee.MulBps(int64(hs.billing.LowBalanceBps))
 	if err != nil {
-		return err
+		return false, err
 	}
-	if newBalance < 0 {
-		err = hs.repo.SuspendClient(ctx, tx, data.ClientID)
+	return balance.Less(threshold)
+}
+
+// jitter spreads d by up to 10% in either direction.
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d / 10)
+	if spread <= 0 {
+		return d
+	}
+	return d - time.Duration(spread) + time.Duration(rand.Int64N(2*spread))
+}
+
+// detach lets a charge that has started commit even if the scheduler is
+// stopping, but for no longer than the charge timeout.
+func (hs *TransactionSchedulerService) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), hs.billing.ChargeTimeout)
+}
+
+// transactionService charges one hour to the client of job. It reports false
+// without error when the client is no longer due, which happens when another
+// replica claimed it first.
+func (hs *TransactionSchedulerService) transactionService(ctx context.Context, job *model.UpdateClient) (bool, error) {
+	ctx, cancel := hs.detach(ctx)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "billing.charge", attribute.String("client_id", job.ClientID.String()))
+
+	start := time.Now()
+	var charged *model.UpdateClient
+	suspended := false
+	err := hs.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// The row lock taken here is what makes charging safe across replicas:
+		// whoever locks the client first charges it, everyone else skips it.
+		data, err := hs.repo.ClaimClient(ctx, job.ClientID, hs.dueBefore())
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		now := hs.clock.Now()
+		tax, taxCarry, err := hs.tax.TaxFor(ctx, data.CostPerHour, data.TaxExempt, now, data.TaxCarry)
+		if err != nil {
+			return err
+		}
+		// The rate is recorded with the charge so converted amounts in reports
+		// never change when newer rates are added.
+		rate, err := hs.fx.RateAt(ctx, data.Currency, now)
+		if err != nil {
+			return err
+		}
+
+		// Balance and fee are changed by delta rather than overwritten from the
+		// snapshot, and the suspension decision uses the balance the database
+		// returns.
+		charge, err := data.CostPerHour.Add(tax)
+		if err != nil {
+			return err
+		}
+		delta, err := charge.Neg()
+		if err != nil {
+			return err
+		}
+		newBalance, err := hs.repo.UpdateBalance(ctx, data.ClientID, delta, model.ReasonHourlyCharge)
 		if err != nil {
 			return err
 		}
-		hs.logger.Warn("Client suspended", zap.Any("client", data))
-	}
 
-	err = tx.Commit(ctx)
+		low, err := hs.lowBalance(newBalance, data.MonthlyFee)
+		if err != nil {
+			return err
+		}
+		if low {
+			utils.Log(ctx, hs.logger).Warn("Client balance is low", zap.Any("client", data), zap.Int64("balance", newBalance.Amount), zap.Int("threshold_bps", hs.billing.LowBalanceBps))
+		}
+
+		if err := hs.repo.UpdateTotalFee(ctx, data.BillingID, data.CostPerHour); err != nil {
+			return err
+		}
+		if err := hs.repo.UpdateClientInfo(ctx, data.ClientID); err != nil {
+			return err
+		}
+		err = hs.repo.InsertTransaction(ctx, &model.Transaction{
+			TransactionID: uuid.New(),
+			ClientID:      data.ClientID,
+			BillingID:     data.BillingID,
+			Type:          model.HourlyChargeTransaction,
+			Amount:        data.CostPerHour,
+			TaxAmount:     tax,
+			BalanceAfter:  newBalance,
+			ExchangeRate:  rate,
+			PeriodStart:   data.UpdatedAt,
+			PeriodEnd:     now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := hs.repo.UpdateBillingInfo(ctx, data.BillingID, taxCarry); err != nil {
+			return err
+		}
+		if newBalance.IsNegative() {
+			if err := hs.repo.SuspendClient(ctx, data.ClientID, model.ReasonNegativeBalance); err != nil {
+				return err
+			}
+			utils.Log(ctx, hs.logger).Warn("Client suspended", zap.Any("client", data))
+			suspended = true
+		}
+		charged = data
+		return nil
+	})
+	metrics.ChargeDuration(metrics.ModeSingle, time.Since(start))
+	span.SetAttributes(attribute.Bool("charged", charged != nil), attribute.Bool("suspended", suspended))
+	tracing.End(span, err)
 	if err != nil {
-		return err
+		metrics.ChargeResult(metrics.ModeSingle, metrics.ChargeFailed)
+		utils.Log(ctx, hs.logger).Error(utils.ErrDatabase.Error(), zap.String("error", "charge rolled back"), zap.Error(err))
+		return false, err
 	}
-
-	return nil
+	if charged == nil {
+		metrics.ChargeResult(metrics.ModeSingle, metrics.ChargeSkipped)
+		return false, nil
+	}
+	metrics.Charged(metrics.ModeSingle, charged.Plan, charged.CostPerHour)
+	if suspended {
+		metrics.Suspended(charged.Plan)
+	}
+	return true, nil
 }
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bagasadiii/maxcloud_vps/model"
+	"github.com/bagasadiii/maxcloud_vps/model/req"
+	"github.com/bagasadiii/maxcloud_vps/repository"
+	"github.com/google/uuid"
+)
+
+// A basic IDR client pays 2000 an hour plus 220 PPN.
+const basicHourlyCharge = 2000 + 220
+
+func TestChargeDueClients(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.register(t, "basic@example.com", model.BasicBilling, model.Rupiah(1500000))
+	start := int64(1500000 - 15000)
+
+	if n, err := env.scheduler.ChargeDueClients(ctx); err != nil || n != 0 {
+		t.Fatalf("charged %d (%v) before the first hour passed", n, err)
+	}
+	const hours = 4
+	for i := 1; i <= hours; i++ {
+		if n := env.advance(t); n != 1 {
+			t.Fatalf("hour %d: charged %d clients, want 1", i, n)
+		}
+		// Charging again within the same hour finds nothing due.
+		if n, err := env.scheduler.ChargeDueClients(ctx); err != nil || n != 0 {
+			t.Fatalf("hour %d: charged %d (%v) twice", i, n, err)
+		}
+	}
+
+	info := env.info(t, id)
+	if want := model.Rupiah(start - hours*basicHourlyCharge); info.Balance != want {
+		t.Errorf("balance = %s, want %s", info.Balance, want)
+	}
+	if info.Uptime != hours || info.TotalFee != model.Rupiah(hours*2000) || info.Suspended {
+		t.Errorf("uptime %d, total fee %s, suspended %t", info.Uptime, info.TotalFee, info.Suspended)
+	}
+	if !info.ClientUpdated.Equal(env.clock.Now()) {
+		t.Errorf("client updated at %s, want %s", info.ClientUpdated, env.clock.Now())
+	}
+
+	transactions := env.repo.Transactions(ctx)
+	if len(transactions) != hours {
+		t.Fatalf("%d transactions, want %d", len(transactions), hours)
+	}
+	for i, tx := range transactions {
+		if tx.ClientID != id || tx.BillingID != info.BillingID || tx.Type != model.HourlyChargeTransaction {
+			t.Errorf("transaction %d = %+v", i, tx)
+		}
+		if tx.Amount != model.Rupiah(2000) || tx.TaxAmount != model.Rupiah(220) || tx.ExchangeRate != model.IdentityRate {
+			t.Errorf("transaction %d charged %s + %s at %s", i, tx.Amount, tx.TaxAmount, tx.ExchangeRate)
+		}
+		if want := model.Rupiah(start - int64(i+1)*basicHourlyCharge); tx.BalanceAfter != want {
+			t.Errorf("transaction %d balance after = %s, want %s", i, tx.BalanceAfter, want)
+		}
+		periodStart := epoch.Add(time.Duration(i) * env.billing.Interval)
+		if !tx.PeriodStart.Equal(periodStart) || !tx.PeriodEnd.Equal(periodStart.Add(env.billing.Interval)) {
+			t.Errorf("transaction %d covers %s to %s", i, tx.PeriodStart, tx.PeriodEnd)
+		}
+	}
+}
+
+func TestChargeDueClientsTaxExempt(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.register(t, "exempt@example.com", model.BasicBilling, model.Rupiah(1500000))
+	if err := env.clients.UpdateClientTaxService(ctx, id, &req.ClientTax{TaxExempt: true}); err != nil {
+		t.Fatal(err)
+	}
+	env.advance(t)
+
+	if got := env.info(t, id).Balance; got != model.Rupiah(1485000-2000) {
+		t.Errorf("balance = %s, want the cost without tax taken", got)
+	}
+	if tx := env.repo.Transactions(ctx)[0]; !tx.TaxAmount.IsZero() {
+		t.Errorf("tax = %s, want 0", tx.TaxAmount)
+	}
+}
+
+func TestChargeDueClientsSuspends(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.register(t, "broke@example.com", model.BasicBilling, model.Rupiah(1500000))
+	rich := env.register(t, "rich@example.com", model.BasicBilling, model.Rupiah(3000000))
+	// Leave 1000, less than one hour.
+	if _, err := env.repo.UpdateBalance(ctx, id, model.Rupiah(-1484000), model.AdjustmentOther); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := env.advance(t); n != 2 {
+		t.Fatalf("charged %d clients, want 2", n)
+	}
+	info := env.info(t, id)
+	if !info.Suspended || info.Balance != model.Rupiah(1000-basicHourlyCharge) || info.Uptime != 1 {
+		t.Fatalf("suspended %t, balance %s, uptime %d after the last hour", info.Suspended, info.Balance, info.Uptime)
+	}
+
+	// Suspended clients are no longer billed, others still are.
+	if n := env.advance(t); n != 1 {
+		t.Fatalf("charged %d clients, want only the active one", n)
+	}
+	if info := env.info(t, id); info.Balance != model.Rupiah(1000-basicHourlyCharge) || info.Uptime != 1 {
+		t.Errorf("suspended client charged again: balance %s, uptime %d", info.Balance, info.Uptime)
+	}
+	if info := env.info(t, rich); info.Uptime != 2 || info.Suspended {
+		t.Errorf("active client uptime %d, suspended %t", info.Uptime, info.Suspended)
+	}
+	if n := len(env.repo.Transactions(ctx)); n != 3 {
+		t.Errorf("%d transactions, want 3", n)
+	}
+}
+
+// failingInsertRepo fails after the balance, fee and timestamp have already
+// been written inside the charge's transaction.
+type failingInsertRepo struct {
+	repository.TransactionSchedulerRepoImpl
+}
+
+var errInsert = errors.New("insert failed")
+
+func (failingInsertRepo) InsertTransaction(ctx context.Context, transaction *model.Transaction) error {
+	return errInsert
+}
+
+func TestChargeRollsBackOnFailure(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	id := env.register(t, "basic@example.com", model.BasicBilling, model.Rupiah(1500000))
+	before := env.info(t, id)
+
+	scheduler := NewTransactionSchedulerService(env.store, failingInsertRepo{env.repo}, env.tax, env.fx, env.billing, env.clock, env.scheduler.logger)
+	env.clock.Advance(env.billing.Interval)
+	if _, err := scheduler.ChargeDueClients(ctx); !errors.Is(err, errInsert) {
+		t.Fatalf("err = %v, want %v", err, errInsert)
+	}
+
+	after := env.info(t, id)
+	if *after != *before {
+		t.Errorf("client changed by a failed charge:\n got %+v\nwant %+v", after, before)
+	}
+	if n := len(env.repo.Transactions(ctx)); n != 0 {
+		t.Errorf("%d transactions recorded", n)
+	}
+	// The client is still due and is charged once the failure is gone.
+	if n := env.advance(t); n != 1 {
+		t.Errorf("charged %d clients after recovering, want 1", n)
+	}
+}
+
+// stuckRepo never gets the client's row lock, like a charge blocked behind a
+// lock that is never released.
+type stuckRepo struct {
+	repository.TransactionSchedulerRepoImpl
+}
+
+func (stuckRepo) ClaimClient(ctx context.Context, clientID uuid.UUID, dueBefore time.Time) (*model.UpdateClient, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// Stopping the scheduler does not cancel a charge in progress, but the
+// charge timeout still ends one that is stuck.
+func TestChargeTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "stuck@example.com", model.BasicBilling, model.Rupiah(1500000))
+	billing := env.billing
+	billing.ChargeTimeout = 50 * time.Millisecond
+	scheduler := NewTransactionSchedulerService(env.store, stuckRepo{env.repo}, env.tax, env.fx, billing, env.clock, env.scheduler.logger)
+	env.clock.Advance(billing.Interval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := scheduler.ChargeDueClients(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("charge still running long after its timeout")
+	}
+}
+
+// benchmarkBatchSize matches the batch size suggested for production.
+const benchmarkBatchSize = 500
+
+// registerMany registers n basic clients with enough balance to never run
+// out during a benchmark.
+func (env *testEnv) registerMany(b *testing.B, n int) {
+	b.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		input := &req.NewClient{Email: fmt.Sprintf("client%d@example.com", i), Balance: 100000000000, Currency: model.IDR, Plan: model.BasicBilling}
+		if err := env.clients.CreateClientService(ctx, input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkCharge charges clients for one hour per iteration, per client
+// when batchSize is 0 and with ChargeDueBatch otherwise.
+func benchmarkCharge(b *testing.B, env *testEnv, clients, batchSize int) {
+	env.registerMany(b, clients)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		env.clock.Advance(env.billing.Interval)
+		charged := 0
+		if batchSize == 0 {
+			n, err := env.scheduler.ChargeDueClients(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			charged = n
+		} else {
+			for {
+				n, err := env.scheduler.chargeBatch(ctx, batchSize)
+				if err != nil {
+					b.Fatal(err)
+				}
+				charged += n
+				if n < batchSize {
+					break
+				}
+			}
+		}
+		if charged != clients {
+			b.Fatalf("charged %d of %d clients", charged, clients)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*clients), "ns/charge")
+}
+
+// BenchmarkCharge measures the service side of both paths. The in-memory
+// Store copies itself for every transaction, which the per-client path opens
+// once per client, so its cost per charge grows with the number of clients
+// and larger counts are left to BenchmarkChargePostgres.
+func BenchmarkCharge(b *testing.B) {
+	for _, clients := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("single/clients=%d", clients), func(b *testing.B) {
+			benchmarkCharge(b, newTestEnv(b), clients, 0)
+		})
+		b.Run(fmt.Sprintf("batch/clients=%d", clients), func(b *testing.B) {
+			benchmarkCharge(b, newTestEnv(b), clients, benchmarkBatchSize)
+		})
+	}
+}
+
+// BenchmarkChargePostgres is where the two paths really differ: the batch
+// charges up to benchmarkBatchSize clients in a few statements instead of
+// several round trips per client.
+func BenchmarkChargePostgres(b *testing.B) {
+	for _, clients := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("single/clients=%d", clients), func(b *testing.B) {
+			benchmarkCharge(b, newPostgresEnv(b), clients, 0)
+		})
+		b.Run(fmt.Sprintf("batch/clients=%d", clients), func(b *testing.B) {
+			benchmarkCharge(b, newPostgresEnv(b), clients, benchmarkBatchSize)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Actor types recorded in the audit log.
+const (
+	ActorSystem = "system"
+	ActorAdmin  = "admin"
+	ActorClient = "client"
+)
+
+// Actor is who a change is made on behalf of. ID is optional, such as the
+// name of a background worker or the admin user.
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+}
+
+type actorKey struct{}
+
+// WithActor records who the work done with ctx is for, and adds it to every
+// log line.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	ctx = context.WithValue(ctx, actorKey{}, actor)
+	return WithLogFields(ctx, zap.String("actor", actor.Type), zap.String("actor_id", actor.ID))
+}
+
+// ActorFrom returns the actor stored by WithActor. Work without one, such
+// as startup tasks, is done by the system.
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Type: ActorSystem}
+}
+
+type auditReasonKey struct{}
+
+// WithAuditReason stores why an admin made a request. It becomes the reason
+// of audit events that don't set their own.
+func WithAuditReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, auditReasonKey{}, reason)
+}
+
+// AuditReason returns the reason stored by WithAuditReason, or "".
+func AuditReason(ctx context.Context) string {
+	reason, _ := ctx.Value(auditReasonKey{}).(string)
+	return reason
+}
@@ -10,10 +10,14 @@ var (
 	ErrNotFound   = errors.New("no data found")
 	ErrDatabase   = errors.New("database error")
 	ErrBadRequest = errors.New("bad request")
-	ErrInternal		= errors.New("internal error")
+	ErrInternal   = errors.New("internal error")
+	ErrValidation = errors.New("validation failed")
+	ErrTooLarge   = errors.New("request body too large")
+	ErrOverflow   = errors.New("amount out of range")
+	ErrCurrency   = errors.New("currency mismatch")
 )
 
-func ErrCheck(err error) (int) {
+func ErrCheck(err error) int {
 	switch {
 	case errors.Is(err, ErrNotFound):
 		return http.StatusNotFound
@@ -21,6 +25,12 @@ func ErrCheck(err error) (int) {
 		return http.StatusInternalServerError
 	case errors.Is(err, ErrInternal):
 		return http.StatusInternalServerError
+	case errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrOverflow):
+		return http.StatusBadRequest
 	case errors.Is(err, ErrBadRequest):
 		return http.StatusBadRequest
 	case errors.Is(err, ErrExists):
@@ -28,4 +38,4 @@ func ErrCheck(err error) (int) {
 	default:
 		return http.StatusInternalServerError
 	}
-}
\ No newline at end of file
+}
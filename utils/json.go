@@ -2,9 +2,15 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 )
 
+// MaxBodyBytes limits the size of JSON request bodies.
+const MaxBodyBytes = 1 << 20
+
 type APIResp struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -23,3 +29,29 @@ func JSONResponse(w http.ResponseWriter, statusCode int, data any) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// DecodeJSON strictly decodes a single JSON object from the request body into dst.
+// Unknown fields, trailing data and bodies larger than MaxBodyBytes are rejected.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return fmt.Errorf("request body is empty: %w", ErrBadRequest)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			return fmt.Errorf("body must not be larger than %d bytes: %w", maxErr.Limit, ErrTooLarge)
+		case errors.Is(err, io.EOF):
+			return fmt.Errorf("request body is empty: %w", ErrBadRequest)
+		default:
+			return fmt.Errorf("invalid JSON body: %v: %w", err, ErrBadRequest)
+		}
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("body must only contain a single JSON object: %w", ErrBadRequest)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type logFieldsKey struct{}
+
+type requestIDKey struct{}
+
+// WithLogFields returns a context whose log lines, see Log, carry fields in
+// addition to any the parent context already has.
+func WithLogFields(ctx context.Context, fields ...zap.Field) context.Context {
+	parent, _ := ctx.Value(logFieldsKey{}).([]zap.Field)
+	all := make([]zap.Field, 0, len(parent)+len(fields))
+	all = append(append(all, parent...), fields...)
+	return context.WithValue(ctx, logFieldsKey{}, all)
+}
+
+// Log returns logger with the fields stored in ctx, so lines logged while
+// handling one request or billing job can be tied together.
+func Log(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	fields, _ := ctx.Value(logFieldsKey{}).([]zap.Field)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// WithRequestID stores the ID of the HTTP request being served and adds it
+// to every log line.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return WithLogFields(ctx, zap.String("request_id", id))
+}
+
+// RequestID returns the ID stored by WithRequestID, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
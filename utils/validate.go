@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes a single invalid field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every FieldError found while validating a request,
+// so the client can fix all of them in one round trip.
+type ValidationErrors []FieldError
+
+func (v *ValidationErrors) Add(field, format string, args ...any) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns nil when no field failed, otherwise the collected errors.
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, fe := range v {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation.Error(), strings.Join(msgs, "; "))
+}
+
+func (v ValidationErrors) Unwrap() error {
+	return ErrValidation
+}